@@ -0,0 +1,381 @@
+// Package anki writes anki packages (.apkg), a zip of a sqlite collection
+// in the anki 2.1 schema (version 11) and the media files of its notes.
+package anki
+
+import (
+	"archive/zip"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// Model is an anki note type.
+type Model struct {
+	Name   string
+	Fields []string
+	// Templates generate a card of each note, e.g. recognition and recall
+	Templates []Template
+	Css       string
+	// Cloze is a cloze note type, which has a card for each cloze
+	// deletion {{c1::...}} of its first template
+	Cloze bool
+}
+
+type Template struct {
+	Name  string
+	Front string
+	Back  string
+}
+
+type Note struct {
+	// Guid identifies the note when imported again, random if empty, see Guid
+	Guid string
+	// Model is the name of the note type, the first one of the deck if empty
+	Model  string
+	Fields []string
+	Tags   []string
+}
+
+type Deck struct {
+	Name   string
+	Models []Model
+	Notes  []Note
+	// Media are the paths of the files referenced by the notes,
+	// by their file name in anki
+	Media map[string]string
+}
+
+// Id derives a stable anki id from name, so a note type or deck is the
+// same one each time it is exported.
+func Id(name string) int64 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	return 1<<30 + int64(h.Sum32()%(1<<30))
+}
+
+// WriteApkg writes deck to the anki package file path.
+func WriteApkg(path string, deck Deck) error {
+	tmpDir, err := os.MkdirTemp("", "apkg")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	collection := filepath.Join(tmpDir, "collection.anki2")
+	if err := writeCollection(collection, deck); err != nil {
+		return fmt.Errorf("cannot write collection: %v", err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = writeZip(f, collection, deck.Media)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+	}
+	return err
+}
+
+func writeZip(w io.Writer, collection string, media map[string]string) error {
+	zw := zip.NewWriter(w)
+	if err := addFile(zw, "collection.anki2", collection); err != nil {
+		return err
+	}
+	// media files are stored as "0", "1", ... and named by the media map
+	var names []string
+	for name := range media {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	mediaMap := map[string]string{}
+	for i, name := range names {
+		if err := addFile(zw, strconv.Itoa(i), media[name]); err != nil {
+			return err
+		}
+		mediaMap[strconv.Itoa(i)] = name
+	}
+	mediaJson, _ := json.Marshal(mediaMap)
+	mw, err := zw.Create("media")
+	if err != nil {
+		return err
+	}
+	if _, err := mw.Write(mediaJson); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func addFile(zw *zip.Writer, name string, path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+const schema = `
+CREATE TABLE col (
+	id integer primary key, crt integer not null, mod integer not null, scm integer not null,
+	ver integer not null, dty integer not null, usn integer not null, ls integer not null,
+	conf text not null, models text not null, decks text not null, dconf text not null, tags text not null
+);
+CREATE TABLE notes (
+	id integer primary key, guid text not null, mid integer not null, mod integer not null,
+	usn integer not null, tags text not null, flds text not null, sfld integer not null,
+	csum integer not null, flags integer not null, data text not null
+);
+CREATE TABLE cards (
+	id integer primary key, nid integer not null, did integer not null, ord integer not null,
+	mod integer not null, usn integer not null, type integer not null, queue integer not null,
+	due integer not null, ivl integer not null, factor integer not null, reps integer not null,
+	lapses integer not null, left integer not null, odue integer not null, odid integer not null,
+	flags integer not null, data text not null
+);
+CREATE TABLE revlog (
+	id integer primary key, cid integer not null, usn integer not null, ease integer not null,
+	ivl integer not null, lastIvl integer not null, factor integer not null, time integer not null,
+	type integer not null
+);
+CREATE TABLE graves (usn integer not null, oid integer not null, type integer not null);
+CREATE INDEX ix_notes_usn on notes (usn);
+CREATE INDEX ix_cards_usn on cards (usn);
+CREATE INDEX ix_revlog_usn on revlog (usn);
+CREATE INDEX ix_cards_nid on cards (nid);
+CREATE INDEX ix_cards_sched on cards (did, queue, due);
+CREATE INDEX ix_revlog_cid on revlog (cid);
+CREATE INDEX ix_notes_csum on notes (csum);
+`
+
+func writeCollection(path string, deck Deck) (err error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := db.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	if _, err := tx.Exec(schema); err != nil {
+		return err
+	}
+
+	if len(deck.Models) == 0 {
+		return fmt.Errorf("no note type")
+	}
+	models := map[string]Model{}
+	for _, model := range deck.Models {
+		models[model.Name] = model
+	}
+	now := time.Now()
+	deckId := Id(deck.Name)
+	conf, modelsJson, decks, dconf := collectionJson(deck, deckId, now)
+	_, err = tx.Exec(`INSERT INTO col VALUES (1, ?, ?, ?, 11, 0, 0, 0, ?, ?, ?, ?, '{}')`,
+		now.Unix(), now.UnixNano()/1e6, now.UnixNano()/1e6, conf, modelsJson, decks, dconf)
+	if err != nil {
+		return err
+	}
+
+	// ids are milliseconds, one for each note and card
+	nextId := now.UnixNano() / 1e6
+	for i, note := range deck.Notes {
+		model := deck.Models[0]
+		if note.Model != "" {
+			var ok bool
+			if model, ok = models[note.Model]; !ok {
+				return fmt.Errorf("note %v has the unknown note type %v", i, note.Model)
+			}
+		}
+		if len(note.Fields) != len(model.Fields) {
+			return fmt.Errorf("note %v has %v fields, want %v", i, len(note.Fields), len(model.Fields))
+		}
+		guid := note.Guid
+		if guid == "" {
+			guid = randomGuid()
+		}
+		noteId := nextId
+		nextId++
+		tags := ""
+		if len(note.Tags) > 0 {
+			tags = " " + strings.Join(note.Tags, " ") + " "
+		}
+		_, err = tx.Exec(`INSERT INTO notes VALUES (?, ?, ?, ?, -1, ?, ?, ?, ?, 0, '')`,
+			noteId, guid, Id(model.Name), now.Unix(), tags, strings.Join(note.Fields, "\x1f"),
+			stripHtml(note.Fields[0]), checksum(note.Fields[0]))
+		if err != nil {
+			return err
+		}
+		for _, ord := range cardOrds(model, note) {
+			_, err = tx.Exec(`INSERT INTO cards VALUES (?, ?, ?, ?, ?, -1, 0, 0, ?, 0, 0, 0, 0, 0, 0, 0, 0, '')`,
+				nextId, noteId, deckId, ord, now.Unix(), i+1)
+			if err != nil {
+				return err
+			}
+			nextId++
+		}
+	}
+	return tx.Commit()
+}
+
+var clozeNumber = regexp.MustCompile(`\{\{c(\d+)::`)
+
+// cardOrds returns the ords of the cards of note, those of the templates
+// or, for a cloze, those of its cloze numbers.
+func cardOrds(model Model, note Note) []int {
+	var ords []int
+	if !model.Cloze {
+		for ord := range model.Templates {
+			ords = append(ords, ord)
+		}
+		return ords
+	}
+	seen := map[int]bool{}
+	for _, field := range note.Fields {
+		for _, m := range clozeNumber.FindAllStringSubmatch(field, -1) {
+			n, _ := strconv.Atoi(m[1])
+			if n > 0 && !seen[n-1] {
+				seen[n-1] = true
+				ords = append(ords, n-1)
+			}
+		}
+	}
+	sort.Ints(ords)
+	return ords
+}
+
+// modelJson is the note type model in the models column.
+func modelJson(model Model, deckId int64, now time.Time) map[string]interface{} {
+	var fields []map[string]interface{}
+	for i, name := range model.Fields {
+		fields = append(fields, map[string]interface{}{
+			"name": name, "ord": i, "sticky": false, "rtl": false,
+			"font": "Arial", "size": 20, "media": []string{},
+		})
+	}
+	var templates []map[string]interface{}
+	var req [][]interface{}
+	for i, t := range model.Templates {
+		templates = append(templates, map[string]interface{}{
+			"name": t.Name, "ord": i, "qfmt": t.Front, "afmt": t.Back,
+			"did": nil, "bqfmt": "", "bafmt": "",
+		})
+		req = append(req, []interface{}{i, "any", []int{0}})
+	}
+	modelType := 0
+	if model.Cloze {
+		// the cards of a cloze do not depend on the templates
+		modelType = 1
+		templates = templates[:1]
+		req = nil
+	}
+	return map[string]interface{}{
+		"id": Id(model.Name), "name": model.Name, "type": modelType, "mod": now.Unix(), "usn": -1,
+		"sortf": 0, "did": deckId, "tmpls": templates, "flds": fields, "css": model.Css,
+		"latexPre":  "\\documentclass[12pt]{article}\n\\special{papersize=3in,5in}\n\\usepackage{amssymb,amsmath}\n\\pagestyle{empty}\n\\setlength{\\parindent}{0in}\n\\begin{document}\n",
+		"latexPost": "\\end{document}", "tags": []string{}, "vers": []string{}, "req": req,
+	}
+}
+
+// collectionJson returns the conf, models, decks and dconf columns of the collection.
+func collectionJson(deck Deck, deckId int64, now time.Time) (conf, models, decks, dconf string) {
+	modelsMap := map[string]interface{}{}
+	for _, model := range deck.Models {
+		modelsMap[strconv.FormatInt(Id(model.Name), 10)] = modelJson(model, deckId, now)
+	}
+	newDeck := func(id int64, name string) map[string]interface{} {
+		return map[string]interface{}{
+			"id": id, "name": name, "mod": now.Unix(), "usn": -1, "desc": "", "dyn": 0,
+			"conf": 1, "collapsed": false, "extendNew": 10, "extendRev": 50,
+			"newToday": []int{0, 0}, "revToday": []int{0, 0}, "lrnToday": []int{0, 0}, "timeToday": []int{0, 0},
+		}
+	}
+	deckConf := map[string]interface{}{
+		"id": 1, "name": "Default", "mod": 0, "usn": 0, "maxTaken": 60, "autoplay": true,
+		"timer": 0, "replayq": true, "dyn": false,
+		"new": map[string]interface{}{
+			"delays": []int{1, 10}, "ints": []int{1, 4, 7}, "initialFactor": 2500,
+			"order": 1, "perDay": 20, "bury": true, "separate": true,
+		},
+		"rev": map[string]interface{}{
+			"perDay": 100, "ease4": 1.3, "fuzz": 0.05, "maxIvl": 36500,
+			"bury": true, "minSpace": 1, "ivlFct": 1,
+		},
+		"lapse": map[string]interface{}{
+			"delays": []int{10}, "mult": 0, "minInt": 1, "leechFails": 8, "leechAction": 0,
+		},
+	}
+	confJson, _ := json.Marshal(map[string]interface{}{
+		"nextPos": len(deck.Notes) + 1, "estTimes": true, "activeDecks": []int64{deckId},
+		"sortType": "noteFld", "timeLim": 0, "sortBackwards": false, "addToCur": true,
+		"curDeck": deckId, "newSpread": 0, "dueCounts": true, "curModel": strconv.FormatInt(Id(deck.Models[0].Name), 10),
+		"collapseTime": 1200,
+	})
+	modelsJson, _ := json.Marshal(modelsMap)
+	decksJson, _ := json.Marshal(map[string]interface{}{
+		"1":                           newDeck(1, "Default"),
+		strconv.FormatInt(deckId, 10): newDeck(deckId, deck.Name),
+	})
+	dconfJson, _ := json.Marshal(map[string]interface{}{"1": deckConf})
+	return string(confJson), string(modelsJson), string(decksJson), string(dconfJson)
+}
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+func stripHtml(s string) string {
+	return strings.TrimSpace(htmlTag.ReplaceAllString(s, ""))
+}
+
+// checksum is the first 8 hex digits of the sha1 of the stripped field,
+// used by anki to find duplicates.
+func checksum(field string) int64 {
+	sum := sha1.Sum([]byte(stripHtml(field)))
+	n, _ := strconv.ParseInt(hex.EncodeToString(sum[:4]), 16, 64)
+	return n
+}
+
+func randomGuid() string {
+	buf := make([]byte, 8)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// Guid derives the guid of a note from its note type and head word, so
+// the note is updated instead of duplicated when imported again.
+func Guid(model string, headword string) string {
+	sum := sha256.Sum256([]byte(model + "\x1f" + headword))
+	return hex.EncodeToString(sum[:8])
+}
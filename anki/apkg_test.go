@@ -0,0 +1,200 @@
+package anki
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWriteApkg(t *testing.T) {
+	dir := t.TempDir()
+	mp3 := filepath.Join(dir, "regret01.mp3")
+	if err := os.WriteFile(mp3, []byte("ID3 fake mp3"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	deck := Deck{
+		Name: "words",
+		Models: []Model{{
+			Name:      "word",
+			Fields:    []string{"Word", "Sound"},
+			Templates: []Template{{Name: "Recognition", Front: "{{Word}}", Back: "{{FrontSide}}{{Sound}}"}},
+			Css:       ".card { color: black; }",
+		}},
+		Notes: []Note{
+			{Guid: "guid-regret", Fields: []string{"<b>regret</b>", "[sound:regret01.mp3]"}, Tags: []string{"webster"}},
+			{Fields: []string{"exhort", ""}},
+		},
+		Media: map[string]string{"regret01.mp3": mp3},
+	}
+	apkg := filepath.Join(dir, "words.apkg")
+	if err := WriteApkg(apkg, deck); err != nil {
+		t.Fatalf("cannot write: %v", err)
+	}
+
+	zr, err := zip.OpenReader(apkg)
+	if err != nil {
+		t.Fatalf("cannot open: %v", err)
+	}
+	defer zr.Close()
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name], _ = io.ReadAll(r)
+		_ = r.Close()
+	}
+	var media map[string]string
+	if err := json.Unmarshal(files["media"], &media); err != nil {
+		t.Fatalf("invalid media map: %v", err)
+	}
+	if len(media) != 1 || media["0"] != "regret01.mp3" || string(files["0"]) != "ID3 fake mp3" {
+		t.Fatalf("unexpected media: %v, %q", media, files["0"])
+	}
+
+	collection := filepath.Join(dir, "collection.anki2")
+	if err := os.WriteFile(collection, files["collection.anki2"], 0644); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite", collection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var models string
+	if err := db.QueryRow(`SELECT models FROM col`).Scan(&models); err != nil {
+		t.Fatalf("cannot read col: %v", err)
+	}
+	if !strings.Contains(models, `"name":"word"`) || !strings.Contains(models, `.card { color: black; }`) {
+		t.Fatalf("model not in collection: %v", models)
+	}
+	var guid, flds, sfld, tags string
+	err = db.QueryRow(`SELECT guid, flds, sfld, tags FROM notes ORDER BY id LIMIT 1`).Scan(&guid, &flds, &sfld, &tags)
+	if err != nil {
+		t.Fatalf("cannot read notes: %v", err)
+	}
+	if guid != "guid-regret" || flds != "<b>regret</b>\x1f[sound:regret01.mp3]" || sfld != "regret" || tags != " webster " {
+		t.Fatalf("unexpected note: %q %q %q %q", guid, flds, sfld, tags)
+	}
+	var notes, cards int
+	_ = db.QueryRow(`SELECT count(*) FROM notes`).Scan(&notes)
+	_ = db.QueryRow(`SELECT count(*) FROM cards`).Scan(&cards)
+	if notes != 2 || cards != 2 {
+		t.Fatalf("want 2 notes and 2 cards, got %v and %v", notes, cards)
+	}
+}
+
+func TestWriteApkg_FieldCount(t *testing.T) {
+	deck := Deck{
+		Name:   "words",
+		Models: []Model{{Name: "word", Fields: []string{"Word", "Sound"}, Templates: []Template{{Name: "Card 1"}}}},
+		Notes:  []Note{{Fields: []string{"regret"}}},
+	}
+	apkg := filepath.Join(t.TempDir(), "words.apkg")
+	if err := WriteApkg(apkg, deck); err == nil {
+		t.Fatalf("want error of missing field")
+	}
+	if _, err := os.Stat(apkg); !os.IsNotExist(err) {
+		t.Fatalf("want no apkg left, got: %v", err)
+	}
+}
+
+func TestWriteApkg_Cloze(t *testing.T) {
+	dir := t.TempDir()
+	deck := Deck{
+		Name: "words",
+		Models: []Model{
+			{Name: "word", Fields: []string{"Word"}, Templates: []Template{{Name: "Recognition", Front: "{{Word}}"}}},
+			{Name: "cloze", Fields: []string{"Word", "Text"}, Templates: []Template{{Name: "Cloze", Front: "{{cloze:Text}}"}}, Cloze: true},
+		},
+		Notes: []Note{
+			{Fields: []string{"regret"}},
+			{Model: "cloze", Fields: []string{"regret", "I {{c1::regret}} it, {{c2::regrets}} and {{c1::regret}}"}},
+		},
+	}
+	apkg := filepath.Join(dir, "words.apkg")
+	if err := WriteApkg(apkg, deck); err != nil {
+		t.Fatalf("cannot write: %v", err)
+	}
+	zr, err := zip.OpenReader(apkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	collection := filepath.Join(dir, "collection.anki2")
+	for _, f := range zr.File {
+		if f.Name != "collection.anki2" {
+			continue
+		}
+		r, _ := f.Open()
+		buf, _ := io.ReadAll(r)
+		_ = r.Close()
+		if err := os.WriteFile(collection, buf, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db, err := sql.Open("sqlite", collection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var models string
+	if err := db.QueryRow(`SELECT models FROM col`).Scan(&models); err != nil {
+		t.Fatal(err)
+	}
+	var parsed map[string]struct {
+		Name string `json:"name"`
+		Type int    `json:"type"`
+	}
+	if err := json.Unmarshal([]byte(models), &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed) != 2 || parsed[strconv.FormatInt(Id("cloze"), 10)].Type != 1 {
+		t.Fatalf("unexpected models: %v", parsed)
+	}
+	rows, err := db.Query(`SELECT notes.mid, cards.ord FROM cards JOIN notes ON cards.nid = notes.id ORDER BY cards.id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var mid int64
+		var ord int
+		_ = rows.Scan(&mid, &ord)
+		got = append(got, fmt.Sprintf("%v:%v", mid, ord))
+	}
+	want := []string{
+		fmt.Sprintf("%v:0", Id("word")),
+		fmt.Sprintf("%v:0", Id("cloze")),
+		fmt.Sprintf("%v:1", Id("cloze")),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want cards %v, got %v", want, got)
+	}
+
+	deck.Notes = append(deck.Notes, Note{Model: "missing", Fields: []string{"exhort"}})
+	if err := WriteApkg(apkg, deck); err == nil {
+		t.Fatalf("want error of unknown note type")
+	}
+}
+
+func TestGuid(t *testing.T) {
+	if Guid("word", "regret") != Guid("word", "regret") {
+		t.Fatalf("guid is not stable")
+	}
+	if Guid("word", "regret") == Guid("word", "exhort") || Guid("word", "regret") == Guid("cloze", "regret") {
+		t.Fatalf("guid is not unique")
+	}
+}
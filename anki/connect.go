@@ -0,0 +1,176 @@
+package anki
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultConnectUrl is where the AnkiConnect add-on listens by default.
+const DefaultConnectUrl = "http://127.0.0.1:8765"
+
+// Client talks the AnkiConnect protocol (version 6) with a running anki.
+type Client struct {
+	url        string
+	httpClient *http.Client
+	// DryRun logs the actions which change the collection instead of
+	// sending them, the queries are still sent.
+	DryRun bool
+}
+
+func NewClient(url string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: time.Minute}
+	}
+	return &Client{url: url, httpClient: httpClient}
+}
+
+// queries are the actions which do not change the collection
+var queries = map[string]bool{
+	"version":    true,
+	"deckNames":  true,
+	"modelNames": true,
+	"findNotes":  true,
+	"notesInfo":  true,
+}
+
+// Invoke sends action and decodes its result into result, unless result is nil.
+func (c *Client) Invoke(ctx context.Context, action string, params interface{}, result interface{}) error {
+	if c.DryRun && !queries[action] {
+		log.Printf("anki dry run: %v", action)
+		return nil
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"action":  action,
+		"version": 6,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("anki connect %v: %v", action, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("anki connect %v: %v", action, resp.Status)
+	}
+	var reply struct {
+		Result json.RawMessage `json:"result"`
+		Error  *string         `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return fmt.Errorf("anki connect %v: invalid reply: %v", action, err)
+	}
+	if reply.Error != nil {
+		return fmt.Errorf("anki connect %v: %v", action, *reply.Error)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(reply.Result, result)
+}
+
+// EnsureDeck creates the deck if missing.
+func (c *Client) EnsureDeck(ctx context.Context, name string) error {
+	var names []string
+	if err := c.Invoke(ctx, "deckNames", nil, &names); err != nil {
+		return err
+	}
+	for _, n := range names {
+		if n == name {
+			return nil
+		}
+	}
+	return c.Invoke(ctx, "createDeck", map[string]interface{}{"deck": name}, nil)
+}
+
+// EnsureModel creates the note type if missing, an existing one is left as is.
+func (c *Client) EnsureModel(ctx context.Context, model Model) error {
+	var names []string
+	if err := c.Invoke(ctx, "modelNames", nil, &names); err != nil {
+		return err
+	}
+	for _, n := range names {
+		if n == model.Name {
+			return nil
+		}
+	}
+	var templates []map[string]string
+	for _, t := range model.Templates {
+		templates = append(templates, map[string]string{"Name": t.Name, "Front": t.Front, "Back": t.Back})
+	}
+	return c.Invoke(ctx, "createModel", map[string]interface{}{
+		"modelName":     model.Name,
+		"inOrderFields": model.Fields,
+		"css":           model.Css,
+		"cardTemplates": templates,
+		"isCloze":       model.Cloze,
+	}, nil)
+}
+
+// SyncNote adds note to deck, or updates the fields of the note of model
+// whose first field, the head word, is the same.
+func (c *Client) SyncNote(ctx context.Context, deck string, model Model, note Note) (added bool, err error) {
+	if len(note.Fields) != len(model.Fields) {
+		return false, fmt.Errorf("note has %v fields, want %v", len(note.Fields), len(model.Fields))
+	}
+	fields := map[string]string{}
+	for i, name := range model.Fields {
+		fields[name] = note.Fields[i]
+	}
+
+	query := fmt.Sprintf(`"note:%v" "%v:%v"`, searchEscape(model.Name), searchEscape(model.Fields[0]), searchEscape(note.Fields[0]))
+	var ids []int64
+	if err := c.Invoke(ctx, "findNotes", map[string]interface{}{"query": query}, &ids); err != nil {
+		return false, err
+	}
+	if len(ids) > 0 {
+		return false, c.Invoke(ctx, "updateNoteFields", map[string]interface{}{
+			"note": map[string]interface{}{"id": ids[0], "fields": fields},
+		}, nil)
+	}
+	tags := note.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return true, c.Invoke(ctx, "addNote", map[string]interface{}{
+		"note": map[string]interface{}{
+			"deckName":  deck,
+			"modelName": model.Name,
+			"fields":    fields,
+			"tags":      tags,
+			"options":   map[string]interface{}{"allowDuplicate": false},
+		},
+	}, nil)
+}
+
+// StoreMediaFile uploads the file path to the media folder of anki as name.
+func (c *Client) StoreMediaFile(ctx context.Context, name string, path string) error {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return c.Invoke(ctx, "storeMediaFile", map[string]interface{}{
+		"filename": name,
+		"data":     base64.StdEncoding.EncodeToString(buf),
+	}, nil)
+}
+
+// searchEscape escapes s for a quoted term of the anki search syntax.
+func searchEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `*`, `\*`, `_`, `\_`, `:`, `\:`).Replace(s)
+}
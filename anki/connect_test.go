@@ -0,0 +1,158 @@
+package anki
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// stubAnki is an in-memory AnkiConnect.
+type stubAnki struct {
+	actions []string
+	decks   []string
+	models  []string
+	notes   map[int64]map[string]string
+	media   map[string][]byte
+}
+
+func newStubAnki(t *testing.T) (*stubAnki, *Client) {
+	stub := &stubAnki{decks: []string{"Default"}, notes: map[int64]map[string]string{}, media: map[string][]byte{}}
+	srv := httptest.NewServer(http.HandlerFunc(stub.serve))
+	t.Cleanup(srv.Close)
+	return stub, NewClient(srv.URL, srv.Client())
+}
+
+func (s *stubAnki) serve(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Action  string
+		Version int
+		Params  struct {
+			Deck     string
+			Query    string
+			Filename string
+			Data     string
+			Model    string `json:"modelName"`
+			Note     struct {
+				Id     int64
+				Fields map[string]string
+			}
+		}
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Version != 6 {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	s.actions = append(s.actions, req.Action)
+	var result interface{}
+	switch req.Action {
+	case "deckNames":
+		result = s.decks
+	case "createDeck":
+		s.decks = append(s.decks, req.Params.Deck)
+	case "modelNames":
+		result = s.models
+	case "createModel":
+		s.models = append(s.models, req.Params.Model)
+	case "findNotes":
+		ids := []int64{}
+		for id, fields := range s.notes {
+			if strings.HasSuffix(req.Params.Query, `"Word:`+fields["Word"]+`"`) {
+				ids = append(ids, id)
+			}
+		}
+		result = ids
+	case "addNote":
+		id := int64(len(s.notes) + 1)
+		s.notes[id] = req.Params.Note.Fields
+		result = id
+	case "updateNoteFields":
+		s.notes[req.Params.Note.Id] = req.Params.Note.Fields
+	case "storeMediaFile":
+		s.media[req.Params.Filename], _ = base64.StdEncoding.DecodeString(req.Params.Data)
+		result = req.Params.Filename
+	default:
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"result": nil, "error": "unsupported action"})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"result": result, "error": nil})
+}
+
+var testModel = Model{
+	Name:      "word",
+	Fields:    []string{"Word", "Sound"},
+	Templates: []Template{{Name: "Recognition", Front: "{{Word}}", Back: "{{FrontSide}}{{Sound}}"}},
+}
+
+func TestClient_Sync(t *testing.T) {
+	stub, client := newStubAnki(t)
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := client.EnsureDeck(ctx, "words"); err != nil {
+			t.Fatalf("cannot create deck: %v", err)
+		}
+		if err := client.EnsureModel(ctx, testModel); err != nil {
+			t.Fatalf("cannot create model: %v", err)
+		}
+	}
+	if !reflect.DeepEqual(stub.decks, []string{"Default", "words"}) || !reflect.DeepEqual(stub.models, []string{"word"}) {
+		t.Fatalf("unexpected decks and models: %v, %v", stub.decks, stub.models)
+	}
+
+	added, err := client.SyncNote(ctx, "words", testModel, Note{Fields: []string{"regret", ""}})
+	if err != nil || !added {
+		t.Fatalf("want added, got %v, %v", added, err)
+	}
+	added, err = client.SyncNote(ctx, "words", testModel, Note{Fields: []string{"regret", "[sound:regret01.mp3]"}})
+	if err != nil || added {
+		t.Fatalf("want updated, got %v, %v", added, err)
+	}
+	if len(stub.notes) != 1 || stub.notes[1]["Sound"] != "[sound:regret01.mp3]" {
+		t.Fatalf("unexpected notes: %v", stub.notes)
+	}
+
+	mp3 := filepath.Join(t.TempDir(), "regret01.mp3")
+	if err := os.WriteFile(mp3, []byte("mp3"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.StoreMediaFile(ctx, "regret01.mp3", mp3); err != nil {
+		t.Fatalf("cannot store media: %v", err)
+	}
+	if string(stub.media["regret01.mp3"]) != "mp3" {
+		t.Fatalf("unexpected media: %v", stub.media)
+	}
+
+	if err := client.Invoke(ctx, "sync", nil, nil); err == nil || !strings.Contains(err.Error(), "unsupported action") {
+		t.Fatalf("want error of anki connect, got: %v", err)
+	}
+}
+
+func TestClient_DryRun(t *testing.T) {
+	stub, client := newStubAnki(t)
+	client.DryRun = true
+	ctx := context.Background()
+	if err := client.EnsureModel(ctx, testModel); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.SyncNote(ctx, "words", testModel, Note{Fields: []string{"regret", ""}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.StoreMediaFile(ctx, "regret01.mp3", "testdata/missing.mp3"); err == nil {
+		t.Fatalf("want error of missing file")
+	}
+	if want := []string{"modelNames", "findNotes"}; !reflect.DeepEqual(stub.actions, want) {
+		t.Fatalf("want only queries %v, got: %v", want, stub.actions)
+	}
+}
+
+func TestSearchEscape(t *testing.T) {
+	if got := searchEscape(`a_b*c:"d"`); got != `a\_b\*c\:\"d\"` {
+		t.Fatalf("unexpected escape: %v", got)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"word-downloader/dict"
+)
+
+// audioPreference selects the audio of the words, for the downloads and
+// the sounds of the cards. Each list has the allowed values in the order
+// of preference, the audio of the other values is not used. An empty list
+// allows all, and so does an unknown accent or voice, after the others.
+type audioPreference struct {
+	accents []string
+	voices  []string
+	dicts   []string
+}
+
+// audioPrefs is the preference of -audio-accents, -audio-voices and
+// -audio-dicts, which allows all until set up.
+var audioPrefs audioPreference
+
+// setupAudioPreference sets audioPrefs from the flags.
+func setupAudioPreference() error {
+	prefs, err := parseAudioPreference(*audioAccents, *audioVoices, *audioDicts)
+	if err != nil {
+		return err
+	}
+	audioPrefs = prefs
+	return nil
+}
+
+// parseAudioPreference parses the comma separated accents, voices and
+// dictionaries of a preference.
+func parseAudioPreference(accents, voices, dicts string) (audioPreference, error) {
+	var prefs audioPreference
+	var err error
+	if prefs.accents, err = parseAudioList("accent", accents, func(s string) bool {
+		return s == dict.AccentUs || s == dict.AccentUk
+	}); err != nil {
+		return prefs, err
+	}
+	if prefs.voices, err = parseAudioList("voice", voices, func(s string) bool {
+		return s == dict.VoiceMale || s == dict.VoiceFemale
+	}); err != nil {
+		return prefs, err
+	}
+	prefs.dicts, err = parseAudioList("dictionary", dicts, func(s string) bool {
+		_, ok := dict.GetProvider(dict.Dictionary(s))
+		return ok
+	})
+	return prefs, err
+}
+
+func parseAudioList(what string, s string, valid func(string) bool) ([]string, error) {
+	var values []string
+	for _, value := range strings.Split(s, ",") {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "" {
+			continue
+		}
+		if !valid(value) {
+			return nil, fmt.Errorf("unknown audio %v '%v'", what, value)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// preferenceRank returns the rank of value in prefs, ok is false if it is
+// not allowed.
+func preferenceRank(prefs []string, value string) (rank int, ok bool) {
+	if len(prefs) == 0 {
+		return 0, true
+	}
+	if value == "" {
+		return len(prefs), true
+	}
+	for i, pref := range prefs {
+		if pref == value {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// allows returns whether the audio of a word of dictionary is used.
+func (p audioPreference) allows(dictionary dict.Dictionary, audio dict.Audio) bool {
+	_, accentOk := preferenceRank(p.accents, audio.Accent)
+	_, voiceOk := preferenceRank(p.voices, audio.Voice)
+	_, dictOk := preferenceRank(p.dicts, string(dictionary))
+	return accentOk && voiceOk && dictOk
+}
+
+// rank returns the allowed audio of the words, the best first: by accent,
+// then dictionary, then voice. accent restricts them to an accent, empty
+// for any.
+func (p audioPreference) rank(words []dict.Word, accent string) []dict.Audio {
+	type rankedAudio struct {
+		dict.Audio
+		ranks [3]int
+	}
+	var ranked []rankedAudio
+	for _, word := range words {
+		for _, audio := range dict.Audios(word) {
+			if accent != "" && audio.Accent != accent || !p.allows(word.Type(), audio) {
+				continue
+			}
+			accentRank, _ := preferenceRank(p.accents, audio.Accent)
+			dictRank, _ := preferenceRank(p.dicts, string(word.Type()))
+			voiceRank, _ := preferenceRank(p.voices, audio.Voice)
+			ranked = append(ranked, rankedAudio{audio, [3]int{accentRank, dictRank, voiceRank}})
+		}
+	}
+	// the words are in the order of the priority of their dictionaries
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i].ranks, ranked[j].ranks
+		for k := range a {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return false
+	})
+	audios := make([]dict.Audio, len(ranked))
+	for i, r := range ranked {
+		audios[i] = r.Audio
+	}
+	return audios
+}
+
+// pickAudios returns count audios of the ranked audios: the best of each
+// accent, e.g. uk then us, and then the next best ones.
+func pickAudios(audios []dict.Audio, count int) []dict.Audio {
+	picked := map[int]bool{}
+	accents := map[string]bool{}
+	var best []int
+	for i, audio := range audios {
+		if len(best) < count && !accents[audio.Accent] {
+			accents[audio.Accent] = true
+			picked[i] = true
+			best = append(best, i)
+		}
+	}
+	for i := range audios {
+		if len(best) < count && !picked[i] {
+			best = append(best, i)
+		}
+	}
+	var result []dict.Audio
+	for _, i := range best {
+		result = append(result, audios[i])
+	}
+	return result
+}
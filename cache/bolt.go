@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+var wordsBucket = []byte("words")
+
+// boltCache keeps one Entry json per key in a bbolt database.
+type boltCache struct {
+	db *bbolt.DB
+}
+
+// OpenBolt opens the bbolt cache at path, creating it if missing.
+func OpenBolt(path string) (Cache, error) {
+	db, err := bbolt.Open(path, 0644, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(wordsBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &boltCache{db: db}, nil
+}
+
+func (c *boltCache) Get(key string) (entry Entry, ok bool, err error) {
+	err = c.db.View(func(tx *bbolt.Tx) error {
+		value := tx.Bucket(wordsBucket).Get([]byte(key))
+		if value == nil {
+			return nil
+		}
+		ok = true
+		return json.Unmarshal(value, &entry)
+	})
+	return entry, ok, err
+}
+
+func (c *boltCache) Put(entry Entry, keys ...string) error {
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return c.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(wordsBucket)
+		for _, key := range keys {
+			if err := bucket.Put([]byte(key), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (c *boltCache) Each(fn func(key string, entry Entry) error) error {
+	return c.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(wordsBucket).ForEach(func(key, value []byte) error {
+			var entry Entry
+			if err := json.Unmarshal(value, &entry); err != nil {
+				return err
+			}
+			return fn(string(key), entry)
+		})
+	})
+}
+
+func (c *boltCache) Close() error {
+	return c.db.Close()
+}
@@ -0,0 +1,46 @@
+// Package cache stores the lookup results of a dictionary, so a word is
+// only queried online once.
+package cache
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Entry is the cached lookup result of a word.
+type Entry struct {
+	// Json is the word json written by dict.Word, empty for a not-found marker
+	Json     json.RawMessage `json:",omitempty"`
+	NotFound bool            `json:",omitempty"`
+	// Reason tells why the word was not found
+	Reason string `json:",omitempty"`
+	// Keyword is the query which was looked up
+	Keyword   string
+	FetchedAt time.Time
+}
+
+// Expired reports whether entry is a not-found marker older than ttl.
+// A zero ttl never expires, markers without FetchedAt always expire.
+func (entry Entry) Expired(ttl time.Duration) bool {
+	return entry.NotFound && ttl > 0 && time.Since(entry.FetchedAt) > ttl
+}
+
+type Cache interface {
+	// Get returns the entry stored under key, ok is false if there is none.
+	Get(key string) (entry Entry, ok bool, err error)
+	// Put stores entry under each of keys, e.g. the keyword and the head word.
+	Put(entry Entry, keys ...string) error
+	// Each calls fn for each key, stopping at the first error.
+	Each(fn func(key string, entry Entry) error) error
+	Close() error
+}
+
+// Import copies all entries of src into dst and returns the number of keys.
+func Import(dst Cache, src Cache) (int, error) {
+	count := 0
+	err := src.Each(func(key string, entry Entry) error {
+		count++
+		return dst.Put(entry, key)
+	})
+	return count, err
+}
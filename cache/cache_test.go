@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+	"word-downloader/dict/webster"
+)
+
+const wordsTxt = `{"W":"regret","Audio":{"Syllables":"re·gret","Pronunciation":"ri-ˈgret","Mp3":""},"Defs":null}
+__not_found:asdfghjk
+{"W":"dexterous","Audio":{"Syllables":"","Pronunciation":"","Mp3":""},"Defs":null}
+{"W":"regret","Audio":{"Syllables":"re·gret","Pronunciation":"ri-ˈgret","Mp3":""},"Defs":null}
+`
+
+func TestFileCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "words.txt")
+	if err := os.WriteFile(path, []byte(wordsTxt), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := OpenFile(path, webster.NewDict().Parse)
+	if err != nil {
+		t.Fatalf("cannot open: %v", err)
+	}
+	defer c.Close()
+
+	entry, ok, _ := c.Get("regret")
+	if !ok || entry.NotFound || entry.Keyword != "regret" {
+		t.Fatalf("unexpected regret entry: %+v, %v", entry, ok)
+	}
+	entry, ok, _ = c.Get("asdfghjk")
+	if !ok || !entry.NotFound {
+		t.Fatalf("unexpected asdfghjk entry: %+v, %v", entry, ok)
+	}
+	if _, ok, _ = c.Get("kestrel"); ok {
+		t.Fatal("want no kestrel entry")
+	}
+
+	fetchedAt := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	qwertyui := Entry{NotFound: true, Keyword: "qwertyui", Reason: "no .hword\ton the page", FetchedAt: fetchedAt}
+	err = c.Put(qwertyui, "qwertyui")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = c.Close()
+	buf, _ := os.ReadFile(path)
+	if string(buf) != wordsTxt+"__not_found:qwertyui\t2021-03-01T00:00:00Z\tno .hword on the page\n" {
+		t.Fatalf("unexpected words.txt: %s", buf)
+	}
+
+	c, err = OpenFile(path, webster.NewDict().Parse)
+	if err != nil {
+		t.Fatalf("cannot reopen: %v", err)
+	}
+	defer c.Close()
+	entry, _, _ = c.Get("qwertyui")
+	qwertyui.Reason = "no .hword on the page"
+	if !reflect.DeepEqual(entry, qwertyui) {
+		t.Fatalf("want %+v, got %+v", qwertyui, entry)
+	}
+}
+
+func TestEntry_Expired(t *testing.T) {
+	old := Entry{NotFound: true, FetchedAt: time.Now().Add(-48 * time.Hour)}
+	recent := Entry{NotFound: true, FetchedAt: time.Now()}
+	word := Entry{Json: []byte(`{}`)}
+	if !old.Expired(24*time.Hour) || recent.Expired(24*time.Hour) || word.Expired(time.Nanosecond) {
+		t.Fatal("unexpected expiry with ttl")
+	}
+	if old.Expired(0) || !(Entry{NotFound: true}).Expired(time.Hour) {
+		t.Fatal("unexpected expiry of zero ttl or time")
+	}
+}
+
+func TestBoltCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "words.db")
+	c, err := OpenBolt(path)
+	if err != nil {
+		t.Fatalf("cannot open: %v", err)
+	}
+	fetchedAt := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	regret := Entry{Json: []byte(`{"W":"regret"}`), Keyword: "Regrets", FetchedAt: fetchedAt}
+	if err := c.Put(regret, "Regrets", "regret"); err != nil {
+		t.Fatal(err)
+	}
+	notFound := Entry{NotFound: true, Keyword: "asdfghjk", FetchedAt: fetchedAt}
+	if err := c.Put(notFound, "asdfghjk"); err != nil {
+		t.Fatal(err)
+	}
+	_ = c.Close()
+
+	// reopen to read from disk
+	c, err = OpenBolt(path)
+	if err != nil {
+		t.Fatalf("cannot reopen: %v", err)
+	}
+	defer c.Close()
+	for key, want := range map[string]Entry{"Regrets": regret, "regret": regret, "asdfghjk": notFound} {
+		got, ok, err := c.Get(key)
+		if err != nil || !ok || !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: want %+v, got %+v, %v, %v", key, want, got, ok, err)
+		}
+	}
+	var keys []string
+	_ = c.Each(func(key string, entry Entry) error {
+		keys = append(keys, key)
+		return nil
+	})
+	if !reflect.DeepEqual(keys, []string{"Regrets", "asdfghjk", "regret"}) {
+		t.Errorf("unexpected keys: %v", keys)
+	}
+}
+
+func TestImport(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "words.txt"), []byte(wordsTxt), 0644); err != nil {
+		t.Fatal(err)
+	}
+	src, err := OpenFile(filepath.Join(dir, "words.txt"), webster.NewDict().Parse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	dst, err := OpenBolt(filepath.Join(dir, "words.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	count, err := Import(dst, src)
+	if err != nil || count != 3 {
+		t.Fatalf("want 3 imported keys, got %v, %v", count, err)
+	}
+	entry, ok, _ := dst.Get("dexterous")
+	if !ok || string(entry.Json) != `{"W":"dexterous","Audio":{"Syllables":"","Pronunciation":"","Mp3":""},"Defs":null}` {
+		t.Fatalf("unexpected dexterous entry: %+v, %v", entry, ok)
+	}
+}
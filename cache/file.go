@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"word-downloader/dict"
+)
+
+const notFoundPrefix = "__not_found:"
+
+// fileCache is the words.txt cache: one word json per line, and a
+// "__not_found:<keyword>\t<time>\t<reason>" line per word the dictionary
+// doesn't have. Time and reason are missing in old files.
+// The whole file is kept in memory.
+type fileCache struct {
+	mu      sync.Mutex
+	f       *os.File
+	entries map[string]Entry
+}
+
+// OpenFile opens the words.txt cache at path, parse decodes its word json.
+func OpenFile(path string, parse func(wordJson []byte) (dict.Word, error)) (Cache, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	c := &fileCache{
+		f:       f,
+		entries: map[string]Entry{},
+	}
+	if err := c.load(parse); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("cannot read %v: %v", path, err)
+	}
+	return c, nil
+}
+
+func (c *fileCache) load(parse func(wordJson []byte) (dict.Word, error)) error {
+	reader := bufio.NewReader(c.f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		text := strings.TrimSpace(string(line))
+		if strings.HasPrefix(text, notFoundPrefix) {
+			entry := parseNotFound(strings.TrimPrefix(text, notFoundPrefix))
+			c.entries[entry.Keyword] = entry
+		} else if text != "" {
+			word, err := parse([]byte(text))
+			if err != nil {
+				return fmt.Errorf("cannot unmarshal word: %v, raw: %v", err, text)
+			}
+			c.entries[word.Word()] = Entry{Json: []byte(text), Keyword: word.Word()}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+func parseNotFound(line string) Entry {
+	fields := strings.SplitN(line, "\t", 3)
+	entry := Entry{NotFound: true, Keyword: fields[0]}
+	if len(fields) > 1 {
+		entry.FetchedAt, _ = time.Parse(time.RFC3339, fields[1])
+	}
+	if len(fields) > 2 {
+		entry.Reason = fields[2]
+	}
+	return entry
+}
+
+func formatNotFound(entry Entry) string {
+	reason := strings.NewReplacer("\t", " ", "\n", " ").Replace(entry.Reason)
+	return notFoundPrefix + entry.Keyword + "\t" + entry.FetchedAt.Format(time.RFC3339) + "\t" + reason
+}
+
+func (c *fileCache) Get(key string) (Entry, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	return entry, ok, nil
+}
+
+func (c *fileCache) Put(entry Entry, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var line string
+	if entry.NotFound {
+		line = formatNotFound(entry) + "\n"
+	} else {
+		line = string(entry.Json) + "\n"
+	}
+	if _, err := c.f.WriteString(line); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		c.entries[key] = entry
+	}
+	return nil
+}
+
+func (c *fileCache) Each(fn func(key string, entry Entry) error) error {
+	c.mu.Lock()
+	keys := make([]string, 0, len(c.entries))
+	for key := range c.entries {
+		keys = append(keys, key)
+	}
+	c.mu.Unlock()
+	sort.Strings(keys)
+	for _, key := range keys {
+		entry, _, _ := c.Get(key)
+		if err := fn(key, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *fileCache) Close() error {
+	if err := c.f.Sync(); err != nil {
+		_ = c.f.Close()
+		return err
+	}
+	return c.f.Close()
+}
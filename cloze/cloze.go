@@ -0,0 +1,119 @@
+// Package cloze turns the example sentences of a word into cloze
+// deletions, masking the word and its inflected forms.
+package cloze
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Mask masks the forms of headword in sentence as the cloze deletion n,
+// e.g. "I {{c1::regretted}} it". ok is false if sentence has no form of
+// headword.
+func Mask(sentence string, headword string, n int) (masked string, ok bool) {
+	re := formsRegexp(headword)
+	if re == nil || !re.MatchString(sentence) {
+		return sentence, false
+	}
+	return re.ReplaceAllStringFunc(sentence, func(form string) string {
+		return fmt.Sprintf("{{c%v::%v}}", n, form)
+	}), true
+}
+
+// Contains tells if sentence has a form of headword.
+func Contains(sentence string, headword string) bool {
+	re := formsRegexp(headword)
+	return re != nil && re.MatchString(sentence)
+}
+
+// formsRegexp matches the forms of headword as whole words, ignoring
+// case. The words of a phrase may be separated by any spaces, only the
+// first word of a phrase is inflected.
+func formsRegexp(headword string) *regexp.Regexp {
+	words := strings.Fields(headword)
+	if len(words) == 0 {
+		return nil
+	}
+	rest := ""
+	for _, w := range words[1:] {
+		rest += `\s+` + regexp.QuoteMeta(w)
+	}
+	forms := Forms(words[0])
+	// the longest first, so "regretted" is not matched as "regret"
+	sort.SliceStable(forms, func(i, j int) bool {
+		return len(forms[i]) > len(forms[j])
+	})
+	var alternatives []string
+	for _, form := range forms {
+		alternatives = append(alternatives, regexp.QuoteMeta(form)+rest)
+	}
+	return regexp.MustCompile(`(?i)\b(?:` + strings.Join(alternatives, "|") + `)\b`)
+}
+
+// Select returns at most max sentences which have a form of headword,
+// the best first: those of a readable length which are complete sentences.
+// Duplicates and sentences with chinese are left out, the order of
+// sentences breaks the ties.
+func Select(sentences []string, headword string, max int) []string {
+	type candidate struct {
+		sentence string
+		score    int
+	}
+	var candidates []candidate
+	seen := map[string]bool{}
+	for _, sentence := range sentences {
+		sentence = strings.Join(strings.Fields(sentence), " ")
+		key := strings.ToLower(sentence)
+		if sentence == "" || seen[key] || hasHan(sentence) || !Contains(sentence, headword) {
+			continue
+		}
+		seen[key] = true
+		candidates = append(candidates, candidate{sentence: sentence, score: penalty(sentence)})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score < candidates[j].score
+	})
+	var selected []string
+	for i := 0; i < len(candidates) && i < max; i++ {
+		selected = append(selected, candidates[i].sentence)
+	}
+	return selected
+}
+
+// The number of words of a sentence of a readable length.
+const (
+	minWords = 5
+	maxWords = 25
+)
+
+// penalty is 0 for a complete sentence of a readable length, and grows
+// as the sentence gets shorter or longer.
+func penalty(sentence string) int {
+	p := 0
+	n := len(strings.Fields(sentence))
+	if n < minWords {
+		p += 2 * (minWords - n)
+	} else if n > maxWords {
+		p += (n - maxWords + 1) / 2
+	}
+	if first := []rune(sentence)[0]; !unicode.IsUpper(first) {
+		p++
+	}
+	if last, _ := utf8.DecodeLastRuneInString(sentence); !strings.ContainsRune(".!?\"'”’…。！？", last) {
+		p++
+	}
+	return p
+}
+
+func hasHan(s string) bool {
+	for _, r := range s {
+		if unicode.Is(unicode.Han, r) {
+			return true
+		}
+	}
+	return false
+}
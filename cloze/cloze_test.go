@@ -0,0 +1,90 @@
+package cloze
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestForms(t *testing.T) {
+	for word, want := range map[string][]string{
+		"regret": {"regrets", "regretted", "regretting"},
+		"carry":  {"carries", "carried", "carrying"},
+		"bake":   {"bakes", "baked", "baking"},
+		"lie":    {"lies", "lay", "lying"},
+		"watch":  {"watches", "watched", "watching"},
+		"run":    {"runs", "ran", "running"},
+		"child":  {"children"},
+		"happy":  {"happier", "happiest"},
+		"leaf":   {"leaves"},
+		"panic":  {"panicked", "panicking"},
+	} {
+		forms := map[string]bool{}
+		for _, form := range Forms(word) {
+			forms[form] = true
+		}
+		for _, form := range append(want, word) {
+			if !forms[form] {
+				t.Errorf("%v: want %v in %v", word, form, Forms(word))
+			}
+		}
+	}
+}
+
+func TestMask(t *testing.T) {
+	for _, tt := range []struct {
+		sentence, headword string
+		want               string
+		ok                 bool
+	}{
+		{"I regretted it, she regrets nothing.", "regret", "I {{c1::regretted}} it, she {{c1::regrets}} nothing.", true},
+		{"Regret is useless.", "regret", "{{c1::Regret}} is useless.", true},
+		{"The kestrel hovered.", "regret", "The kestrel hovered.", false},
+		// not a part of another word
+		{"She is irregular.", "regular", "She is irregular.", false},
+		{"He gave   up   smoking.", "give up", "He {{c1::gave   up}}   smoking.", true},
+		{"The children ran.", "child", "The {{c1::children}} ran.", true},
+	} {
+		got, ok := Mask(tt.sentence, tt.headword, 1)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Mask(%q, %q) = %q, %v, want %q, %v", tt.sentence, tt.headword, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	sentences := []string{
+		"regrets his mistakes",
+		"A kestrel hovered over the field.",
+		"I regret that I cannot come",
+		"我后悔说了那话。",
+		"She expressed deep regret at the news.",
+		"she expressed deep  regret at the news.",
+		"I regret saying that to him yesterday, and I have regretted it ever since, though he said that he forgave me long ago and that it was nothing worth remembering.",
+	}
+	want := []string{
+		"She expressed deep regret at the news.",
+		"I regret that I cannot come",
+		"I regret saying that to him yesterday, and I have regretted it ever since, though he said that he forgave me long ago and that it was nothing worth remembering.",
+	}
+	if got := Select(sentences, "regret", 3); !reflect.DeepEqual(got, want) {
+		t.Fatalf("want:\n%q\ngot:\n%q", want, got)
+	}
+	if got := Select(sentences, "regret", 1); len(got) != 1 || got[0] != want[0] {
+		t.Fatalf("want the best sentence only, got: %q", got)
+	}
+}
+
+func TestPenalty(t *testing.T) {
+	for _, sentence := range []string{
+		"She said: \u201cI regret nothing at all.\u201d",
+		"She said that she regretted nothing at all\u2026",
+		"She said that she regretted nothing at all.",
+	} {
+		if p := penalty(sentence); p != 0 {
+			t.Errorf("want no penalty of an ended sentence %q, got: %v", sentence, p)
+		}
+	}
+	if p := penalty("She said that she regretted nothing at all"); p != 1 {
+		t.Errorf("want a penalty of an unended sentence, got: %v", p)
+	}
+}
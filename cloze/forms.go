@@ -0,0 +1,103 @@
+package cloze
+
+import (
+	"strings"
+	"word-downloader/lemma"
+)
+
+// Forms returns word and the forms it may be inflected to: the irregular
+// ones and those of the regular suffixes -s, -ed, -ing, -er and -est.
+// Some of the regular forms do not exist, they are never found in a
+// sentence anyway.
+func Forms(word string) []string {
+	word = strings.ToLower(word)
+	forms := []string{word}
+	seen := map[string]bool{word: true}
+	add := func(form string) {
+		if !seen[form] {
+			seen[form] = true
+			forms = append(forms, form)
+		}
+	}
+	for _, form := range lemma.IrregularForms(word) {
+		add(form)
+	}
+	if len(word) < 2 || !isLetters(word) {
+		return forms
+	}
+
+	last := word[len(word)-1]
+	stem := word[:len(word)-1]
+	switch {
+	case strings.HasSuffix(word, "s") || strings.HasSuffix(word, "x") || strings.HasSuffix(word, "z") ||
+		strings.HasSuffix(word, "ch") || strings.HasSuffix(word, "sh") || strings.HasSuffix(word, "o"):
+		add(word + "es")
+		add(word + "s")
+	case last == 'y' && !isVowel(word[len(word)-2]):
+		add(stem + "ies")
+	case strings.HasSuffix(word, "f"):
+		add(stem + "ves")
+		add(word + "s")
+	case strings.HasSuffix(word, "fe"):
+		add(word[:len(word)-2] + "ves")
+		add(word + "s")
+	default:
+		add(word + "s")
+	}
+
+	for _, suffix := range []string{"ed", "er", "est"} {
+		switch {
+		case last == 'e':
+			add(stem + suffix)
+		case last == 'y' && !isVowel(word[len(word)-2]):
+			add(stem + "i" + suffix)
+		default:
+			add(word + suffix)
+			if endsWithCvc(word) {
+				add(word + string(last) + suffix)
+			}
+		}
+	}
+
+	switch {
+	case strings.HasSuffix(word, "ie"):
+		add(word[:len(word)-2] + "ying")
+	case last == 'e' && !strings.HasSuffix(word, "ee") && !strings.HasSuffix(word, "ye") && !strings.HasSuffix(word, "oe"):
+		add(stem + "ing")
+	default:
+		add(word + "ing")
+		if endsWithCvc(word) {
+			add(word + string(last) + "ing")
+		}
+	}
+	if last == 'c' {
+		// panic, panicked
+		add(word + "ked")
+		add(word + "king")
+	}
+	return forms
+}
+
+func isVowel(c byte) bool {
+	return strings.IndexByte("aeiou", c) >= 0
+}
+
+func isLetters(word string) bool {
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// endsWithCvc tells if word ends with a consonant, vowel and consonant,
+// whose consonant may be doubled before a suffix, e.g. regret, regretted.
+func endsWithCvc(word string) bool {
+	n := len(word)
+	if n < 3 {
+		return false
+	}
+	c1, v, c2 := word[n-3], word[n-2], word[n-1]
+	return !isVowel(c1) && isVowel(v) && !isVowel(c2) && strings.IndexByte("wxy", c2) < 0
+}
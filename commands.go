@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"word-downloader/cache"
+	"word-downloader/dict"
+	"word-downloader/media"
+)
+
+// migrateCache imports the words.txt of each dictionary into its words.db.
+// words.txt is left untouched.
+func migrateCache(myDicts []dict.Dict) {
+	for _, d := range myDicts {
+		dst, err := cache.OpenBolt(filepath.Join(dictDir(d.Type()), "words.db"))
+		if err != nil {
+			log.Fatalf("error: cannot open words.db of %v: %v", d.Type(), err)
+		}
+		count, err := importWordsTxt(d, dst)
+		if err != nil {
+			log.Fatalf("error: cannot import words.txt of %v: %v", d.Type(), err)
+		}
+		if err := dst.Close(); err != nil {
+			log.Fatalf("error: cannot close words.db of %v: %v", d.Type(), err)
+		}
+		log.Printf("%v: imported %v words", d.Type(), count)
+	}
+}
+
+// importWordsTxt imports the words.txt of dictionary into dst, and returns
+// the number of words imported.
+func importWordsTxt(dictionary dict.Dict, dst cache.Cache) (int, error) {
+	src, err := cache.OpenFile(filepath.Join(dictDir(dictionary.Type()), "words.txt"), dictionary.Parse)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+	return cache.Import(dst, src)
+}
+
+// recheckNotFound queries the not found words of each dictionary again,
+// and prints those which are found now as "<dictionary> <keyword> <head word>".
+func recheckNotFound(ctx context.Context, myDicts []dict.Dict) {
+	downloaders := newDownloaders(myDicts)
+	for _, d := range downloaders {
+		defer d.close()
+	}
+
+	for _, d := range downloaders {
+		var keywords []string
+		err := d.cache.Each(func(key string, entry cache.Entry) error {
+			if entry.NotFound {
+				keywords = append(keywords, key)
+			}
+			return nil
+		})
+		if err != nil {
+			log.Fatalf("error: cannot read cache of %v: %v", d.dict.Type(), err)
+		}
+
+		resolved := 0
+		for _, keyword := range keywords {
+			if ctx.Err() != nil {
+				log.Printf("interrupted")
+				return
+			}
+			word, err := d.lookupOnline(ctx, keyword)
+			if err != nil {
+				continue
+			}
+			resolved++
+			fmt.Printf("%v\t%v\t%v\n", d.dict.Type(), keyword, word.Word())
+		}
+		log.Printf("%v: %v of %v not found words are found now", d.dict.Type(), resolved, len(keywords))
+	}
+}
+
+// mediaCommand runs "media <sub>":
+//   - process: process the audio of the media store, see processMedia
+//   - verify: download the invalid files again, see verifyMedia
+func mediaCommand(ctx context.Context, myDicts []dict.Dict, sub string) {
+	switch sub {
+	case "process":
+		processMedia(ctx)
+	case "verify":
+		verifyMedia(ctx, myDicts)
+	default:
+		_, _ = fmt.Fprintf(os.Stderr, "unknown command: media %v\n", sub)
+		flag.Usage()
+		os.Exit(1)
+	}
+	if err := mediaStore.Save(); err != nil {
+		log.Fatalf("error: cannot write media index: %v", err)
+	}
+}
+
+// processMedia processes the audio of the media store which is not yet,
+// e.g. that downloaded before -audio-process is set.
+func processMedia(ctx context.Context) {
+	names, err := mediaStore.Files(media.KindAudio)
+	if err != nil {
+		log.Fatalf("error: cannot read media store: %v", err)
+	}
+	for _, name := range names {
+		if ctx.Err() != nil {
+			log.Printf("interrupted")
+			return
+		}
+		processAudio(name)
+	}
+	log.Printf("%v audio files are processed", len(names))
+}
+
+// mediaDownload is a media file of a word to download again.
+type mediaDownload struct {
+	keyword string
+	url     string
+	kind    media.Kind
+}
+
+// verifyMedia validates the audio and pictures of the media store, and
+// the mp3 stored by the earlier versions. The invalid files, e.g. the
+// error pages of the servers, are removed, and those of the words of the
+// caches are downloaded again. The failures are written to audio-error.txt.
+func verifyMedia(ctx context.Context, myDicts []dict.Dict) {
+	checked, invalid := 0, 0
+	// the urls of the invalid files
+	invalidUrls := map[string]bool{}
+	for _, kind := range []media.Kind{media.KindAudio, media.KindPicture} {
+		names, err := mediaStore.Files(kind)
+		if err != nil {
+			log.Fatalf("error: cannot read media store: %v", err)
+		}
+		for _, name := range names {
+			checked++
+			err := media.ValidateFile(mediaStore.Path(name))
+			if err == nil {
+				continue
+			}
+			log.Printf(" invalid: %v: %v", name, err)
+			invalid++
+			urls, err := mediaStore.Remove(name)
+			if err != nil {
+				log.Fatalf("error: cannot remove %v: %v", name, err)
+			}
+			for _, u := range urls {
+				invalidUrls[u] = true
+			}
+		}
+	}
+	// the downloads interrupted by a kill
+	if err := os.RemoveAll(mediaStore.TempDir()); err != nil {
+		log.Printf("error: cannot remove %v: %v", mediaStore.TempDir(), err)
+	}
+
+	downloaders := newDownloaders(myDicts)
+	for _, d := range downloaders {
+		defer d.close()
+	}
+	for _, d := range downloaders {
+		entries, err := os.ReadDir(d.audioDir)
+		if err != nil && !os.IsNotExist(err) {
+			log.Fatalf("error: cannot read %v: %v", d.audioDir, err)
+		}
+		// the invalid mp3 of the earlier versions
+		invalidLegacy := map[string]bool{}
+		for _, entry := range entries {
+			file := filepath.Join(d.audioDir, entry.Name())
+			if !entry.Type().IsRegular() {
+				continue
+			}
+			checked++
+			err := media.ValidateFile(file)
+			if err == nil {
+				continue
+			}
+			log.Printf(" invalid: %v: %v", file, err)
+			invalid++
+			if err := os.Remove(file); err != nil {
+				log.Fatalf("error: cannot remove %v: %v", file, err)
+			}
+			invalidLegacy[entry.Name()] = true
+		}
+		if len(invalidUrls) == 0 && len(invalidLegacy) == 0 {
+			continue
+		}
+
+		var downloads []mediaDownload
+		err = d.cache.Each(func(key string, entry cache.Entry) error {
+			if entry.NotFound {
+				return nil
+			}
+			word, err := d.dict.Parse(entry.Json)
+			if err != nil {
+				return fmt.Errorf("cannot parse %v: %v", key, err)
+			}
+			for _, audio := range dict.Audios(word) {
+				if invalidUrls[audio.Url] || invalidLegacy[path.Base(audio.Url)] {
+					downloads = append(downloads, mediaDownload{key, audio.Url, media.KindAudio})
+				}
+			}
+			for _, u := range dict.Pictures(word) {
+				if invalidUrls[u] {
+					downloads = append(downloads, mediaDownload{key, u, media.KindPicture})
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Fatalf("error: cannot read cache of %v: %v", d.dict.Type(), err)
+		}
+
+		downloaded := 0
+		for _, download := range downloads {
+			if ctx.Err() != nil {
+				log.Printf("interrupted")
+				return
+			}
+			var err error
+			if download.kind == media.KindAudio {
+				_, err = d.downloadMp3(ctx, download.url)
+			} else {
+				_, err = d.downloadPic(ctx, download.url)
+			}
+			if err != nil {
+				log.Printf("error: cannot download '%v': %v", download.url, err)
+				d.recordFailure(download.keyword, download.url, err)
+				continue
+			}
+			downloaded++
+		}
+		log.Printf("%v: %v of %v invalid media files are downloaded again", d.dict.Type(), downloaded, len(downloads))
+	}
+	log.Printf("%v of %v media files are invalid", invalid, checked)
+}
+
+// configCommand runs "config <sub>", only "config validate" for now.
+func configCommand(sub string) {
+	if sub != "validate" {
+		_, _ = fmt.Fprintf(os.Stderr, "unknown command: config %v\n", sub)
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *configPath == "" {
+		_, _ = fmt.Fprintf(os.Stderr, "error: no config file, use -config\n")
+		os.Exit(1)
+	}
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	errs := config.validate()
+	for _, err := range errs {
+		_, _ = fmt.Fprintf(os.Stderr, "%v: %v\n", *configPath, err)
+	}
+	if len(errs) > 0 {
+		os.Exit(1)
+	}
+	fmt.Printf("%v: ok\n", *configPath)
+}
+
+// writeTemplates writes the default templates and card.css to dir, the
+// existing files are left untouched.
+func writeTemplates(dir string) {
+	if dir == "" {
+		_, _ = fmt.Fprintf(os.Stderr, "error: missing directory, usage: templates <dir>\n")
+		os.Exit(1)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Fatalf("error: cannot mkdir: %v", err)
+	}
+	names, texts := dict.DefaultTemplates()
+	files := map[string]string{"card.css": ankiCardCss}
+	for _, name := range names {
+		files[name+".html"] = texts[name]
+	}
+	for file, text := range files {
+		path := filepath.Join(dir, file)
+		if _, err := os.Stat(path); err == nil {
+			log.Printf("skip %v: already exists", path)
+			continue
+		}
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			log.Fatalf("error: cannot write %v: %v", path, err)
+		}
+		log.Printf("write %v", path)
+	}
+}
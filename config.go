@@ -0,0 +1,557 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"word-downloader/dict"
+	"word-downloader/dict/collins"
+	"word-downloader/wordlist"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the content of the -config file, see config.example.yaml.
+// The flags given on the command line override its values.
+type Config struct {
+	DataDir string `yaml:"data_dir"`
+	// TemplatesDir holds the templates replacing the default ones
+	TemplatesDir string `yaml:"templates_dir"`
+	WordList     string `yaml:"word_list"`
+	// WordListFormat is the format of the word list, auto by default
+	WordListFormat string `yaml:"word_list_format"`
+	// CsvColumn is the column of a csv word list, its index or name
+	CsvColumn    string        `yaml:"csv_column"`
+	Concurrency  int           `yaml:"concurrency"`
+	Cache        string        `yaml:"cache"`
+	NotFoundTTL  time.Duration `yaml:"not_found_ttl"`
+	Timeout      time.Duration `yaml:"timeout"`
+	Retries      *int          `yaml:"retries"`
+	RetryBackoff time.Duration `yaml:"retry_backoff"`
+	// Lemmatize looks up the lemma of the words, false by default
+	Lemmatize *bool `yaml:"lemmatize"`
+	// Vocabulary is the word list of the known lemmas
+	Vocabulary string `yaml:"vocabulary"`
+	// Preprocess filters the word list before the lookup
+	Preprocess PreprocessConfig `yaml:"preprocess"`
+	// Dictionaries are the enabled dictionaries, unless -dicts is given
+	Dictionaries []DictConfig `yaml:"dictionaries"`
+	Media        MediaConfig  `yaml:"media"`
+	Export       ExportConfig `yaml:"export"`
+}
+
+type PreprocessConfig struct {
+	Dedupe          bool `yaml:"dedupe"`
+	Lowercase       bool `yaml:"lowercase"`
+	AlphaOnly       bool `yaml:"alpha_only"`
+	SkipProperNouns bool `yaml:"skip_proper_nouns"`
+	// FrequencyList is the word frequency list of 'word count' lines
+	FrequencyList string `yaml:"frequency_list"`
+	MinFrequency  int    `yaml:"min_frequency"`
+	// Top only keeps the top most frequent words, 0 for all
+	Top int `yaml:"top"`
+	// SkipStatus are the statuses of the skipped words, known and ignored
+	// by default
+	SkipStatus []string `yaml:"skip_status"`
+}
+
+type DictConfig struct {
+	Name      string `yaml:"name"`
+	BaseUrl   string `yaml:"base_url"`
+	Proxy     string `yaml:"proxy"`
+	UserAgent string `yaml:"user_agent"`
+	// Rate is the online lookup rate, in the format of -rate, e.g. 2/s
+	Rate    string        `yaml:"rate"`
+	Timeout time.Duration `yaml:"timeout"`
+	// Backend and Selenium are for collins only
+	Backend  string          `yaml:"backend"`
+	Selenium *SeleniumConfig `yaml:"selenium"`
+	// Params are the settings specific to the provider, see dict.WithParam
+	Params map[string]string `yaml:"params"`
+}
+
+// SeleniumConfig overrides the non empty values of collins.DefaultSeleniumConfig.
+type SeleniumConfig struct {
+	SeleniumPath     string `yaml:"selenium_path"`
+	ChromeDriverPath string `yaml:"chromedriver_path"`
+	ChromePath       string `yaml:"chrome_path"`
+	Port             int    `yaml:"port"`
+	// Proxy of the browser, "" for a direct connection
+	Proxy *string `yaml:"proxy"`
+}
+
+type MediaConfig struct {
+	DownloadMp3 *bool `yaml:"download_mp3"`
+	DownloadPic *bool `yaml:"download_pic"`
+	// PicMaxSize is the max width and height of the thumbnails
+	PicMaxSize *int        `yaml:"pic_max_size"`
+	Audio      AudioConfig `yaml:"audio"`
+}
+
+// AudioConfig is the preference of the audio which is downloaded and
+// exported, each list in the order of preference, see audioPreference.
+type AudioConfig struct {
+	// Accents are us and uk, empty for all
+	Accents []string `yaml:"accents"`
+	// Voices are female and male, empty for all
+	Voices []string `yaml:"voices"`
+	// Dicts are the dictionaries, empty for all
+	Dicts []string `yaml:"dicts"`
+	// Clips is the number of sounds of an audio field, unless it has a count
+	Clips *int `yaml:"clips"`
+	// Process trims the silence and normalizes the loudness of the audio,
+	// to Loudness in dBFS, the sound under Silence in dBFS is trimmed
+	Process  *bool    `yaml:"process"`
+	Loudness *float64 `yaml:"loudness"`
+	Silence  *float64 `yaml:"silence"`
+}
+
+type ExportConfig struct {
+	// AnkiCsv is the path of the anki csv file, empty for no csv
+	AnkiCsv string `yaml:"anki_csv"`
+	// AnkiApkg is the path of the anki package, empty for no package
+	AnkiApkg string `yaml:"anki_apkg"`
+	AnkiDeck string `yaml:"anki_deck"`
+	// AnkiConnect is the url of AnkiConnect, empty for no sync
+	AnkiConnect       string `yaml:"anki_connect"`
+	AnkiConnectDryRun bool   `yaml:"anki_connect_dry_run"`
+	// Incremental only exports the new or changed cards
+	Incremental bool `yaml:"incremental"`
+	// AnkiCloze adds cloze notes of the example sentences, unless a
+	// cloze is in note_types
+	AnkiCloze bool `yaml:"anki_cloze"`
+	// NoteTypes are the note types built from each word, the csv file has
+	// the notes of the first one. The default is a recognition note type.
+	NoteTypes []NoteTypeConfig `yaml:"note_types"`
+}
+
+// NoteTypeConfig is an anki note type, its card is given by its kind:
+// recognition, recall, cloze or listening.
+type NoteTypeConfig struct {
+	Name string `yaml:"name"`
+	Kind string `yaml:"kind"`
+	// Fields default to those referenced by the templates of the kind,
+	// the first one must be the word
+	Fields []FieldConfig `yaml:"fields"`
+	// Front and Back replace the templates of the kind
+	Front string `yaml:"front"`
+	Back  string `yaml:"back"`
+}
+
+// FieldConfig maps a field of a note type to the data of a dictionary.
+type FieldConfig struct {
+	Name string `yaml:"name"`
+	// Source is word, pronunciation, definition, basic-definition,
+	// example, cloze, audio or picture
+	Source string `yaml:"source"`
+	// Dict takes the data from this dictionary only, by default from the
+	// first dictionary which has it
+	Dict string `yaml:"dict"`
+	// Accent of the audio, us or uk, empty for any
+	Accent string `yaml:"accent"`
+	// Count is the number of sentences of an example or cloze, 1 by
+	// default, or the number of sounds of an audio, media.audio.clips by
+	// default
+	Count int `yaml:"count"`
+}
+
+// appConfig is the loaded -config, empty without one.
+var appConfig = &Config{}
+
+func loadConfig(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	config := &Config{}
+	if err := decoder.Decode(config); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("cannot parse %v: %v", path, err)
+	}
+	return config, nil
+}
+
+// setupConfig loads -config into appConfig and sets the flags which are
+// not given on the command line.
+func setupConfig() {
+	if *configPath == "" {
+		return
+	}
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	if errs := config.validate(); len(errs) > 0 {
+		for _, err := range errs {
+			_, _ = fmt.Fprintf(os.Stderr, "error: %v: %v\n", *configPath, err)
+		}
+		os.Exit(1)
+	}
+	if err := applyConfig(flag.CommandLine, config); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "error: %v: %v\n", *configPath, err)
+		os.Exit(1)
+	}
+	appConfig = config
+}
+
+// validate returns all the problems of config.
+func (config *Config) validate() []error {
+	var errs []error
+	if config.Cache != "" && config.Cache != "bolt" && config.Cache != "file" {
+		errs = append(errs, fmt.Errorf("cache: unknown cache type '%v'", config.Cache))
+	}
+	if format := config.WordListFormat; format != "" && format != wordlist.FormatAuto {
+		known := false
+		for _, f := range wordlist.Formats() {
+			known = known || f == format
+		}
+		if !known {
+			errs = append(errs, fmt.Errorf("word_list_format: unknown format '%v'", format))
+		}
+	}
+	if config.Concurrency < 0 {
+		errs = append(errs, fmt.Errorf("concurrency: must not be negative"))
+	}
+	if config.Retries != nil && *config.Retries < 0 {
+		errs = append(errs, fmt.Errorf("retries: must not be negative"))
+	}
+	if config.NotFoundTTL < 0 {
+		errs = append(errs, fmt.Errorf("not_found_ttl: must not be negative"))
+	}
+	if config.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("timeout: must not be negative"))
+	}
+	if config.RetryBackoff < 0 {
+		errs = append(errs, fmt.Errorf("retry_backoff: must not be negative"))
+	}
+	if config.Media.PicMaxSize != nil && *config.Media.PicMaxSize < 0 {
+		errs = append(errs, fmt.Errorf("media: pic_max_size: must not be negative"))
+	}
+	audio := config.Media.Audio
+	if _, err := parseAudioPreference(strings.Join(audio.Accents, ","), strings.Join(audio.Voices, ","), strings.Join(audio.Dicts, ",")); err != nil {
+		errs = append(errs, fmt.Errorf("media: audio: %v", err))
+	}
+	if audio.Clips != nil && *audio.Clips < 1 {
+		errs = append(errs, fmt.Errorf("media: audio: clips: must be at least 1"))
+	}
+	if audio.Loudness != nil && *audio.Loudness >= 0 {
+		errs = append(errs, fmt.Errorf("media: audio: loudness: must be negative"))
+	}
+	if audio.Silence != nil && *audio.Silence >= 0 {
+		errs = append(errs, fmt.Errorf("media: audio: silence: must be negative"))
+	} else if audio.Silence != nil && audio.Loudness != nil && *audio.Silence >= *audio.Loudness {
+		errs = append(errs, fmt.Errorf("media: audio: silence: must be under the loudness"))
+	}
+	if config.Preprocess.MinFrequency < 0 {
+		errs = append(errs, fmt.Errorf("preprocess: min_frequency: must not be negative"))
+	} else if config.Preprocess.MinFrequency > 0 && config.Preprocess.FrequencyList == "" {
+		errs = append(errs, fmt.Errorf("preprocess: min_frequency: needs a frequency_list"))
+	}
+	if config.Preprocess.Top < 0 {
+		errs = append(errs, fmt.Errorf("preprocess: top: must not be negative"))
+	}
+	for _, status := range config.Preprocess.SkipStatus {
+		if _, err := wordlist.ParseStatus(status); err != nil {
+			errs = append(errs, fmt.Errorf("preprocess: skip_status: %v", err))
+		}
+	}
+
+	if config.Export.AnkiConnect != "" {
+		if err := checkUrl(config.Export.AnkiConnect); err != nil {
+			errs = append(errs, fmt.Errorf("export: anki_connect: %v", err))
+		}
+	}
+
+	names := map[string]bool{}
+	for i, nc := range config.Export.NoteTypes {
+		if _, err := newNoteType(nc); err != nil {
+			errs = append(errs, fmt.Errorf("export: note_types[%v]: %v", i, err))
+		} else if names[nc.Name] {
+			errs = append(errs, fmt.Errorf("export: note_types[%v]: duplicated note type '%v'", i, nc.Name))
+		}
+		names[nc.Name] = true
+	}
+
+	seen := map[string]bool{}
+	for i, dc := range config.Dictionaries {
+		where := fmt.Sprintf("dictionaries[%v]", i)
+		if dc.Name != "" {
+			where = fmt.Sprintf("dictionaries[%v] (%v)", i, dc.Name)
+		}
+		if _, ok := dict.GetProvider(dict.Dictionary(dc.Name)); !ok {
+			errs = append(errs, fmt.Errorf("%v: unsupported dictionary '%v'", where, dc.Name))
+		} else if seen[dc.Name] {
+			errs = append(errs, fmt.Errorf("%v: duplicated dictionary", where))
+		}
+		seen[dc.Name] = true
+		if dc.BaseUrl != "" {
+			if err := checkUrl(dc.BaseUrl); err != nil {
+				errs = append(errs, fmt.Errorf("%v: base_url: %v", where, err))
+			}
+		}
+		if dc.Proxy != "" {
+			if err := checkUrl(dc.Proxy); err != nil {
+				errs = append(errs, fmt.Errorf("%v: proxy: %v", where, err))
+			}
+		}
+		if dc.Rate != "" {
+			if _, err := parseRate(dc.Rate); err != nil {
+				errs = append(errs, fmt.Errorf("%v: rate: %v", where, err))
+			}
+		}
+		if dc.Timeout < 0 {
+			errs = append(errs, fmt.Errorf("%v: timeout: must not be negative", where))
+		}
+		if dict.Dictionary(dc.Name) != dict.Collins {
+			if dc.Backend != "" || dc.Selenium != nil {
+				errs = append(errs, fmt.Errorf("%v: backend and selenium are for collins only", where))
+			}
+			continue
+		}
+		switch collins.Backend(dc.Backend) {
+		case "", collins.HttpBackend, collins.SeleniumBackend, collins.FallbackBackend:
+		default:
+			errs = append(errs, fmt.Errorf("%v: unknown backend '%v'", where, dc.Backend))
+		}
+		if dc.Selenium != nil && dc.Selenium.Port < 0 {
+			errs = append(errs, fmt.Errorf("%v: selenium: port must not be negative", where))
+		}
+	}
+	return errs
+}
+
+func checkUrl(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("'%v' is not an absolute url", raw)
+	}
+	return nil
+}
+
+// flagValues returns the values config gives to the flags, by flag name.
+func (config *Config) flagValues() map[string]string {
+	values := map[string]string{}
+	if config.DataDir != "" {
+		values["data-dir"] = config.DataDir
+	}
+	if config.TemplatesDir != "" {
+		values["templates"] = config.TemplatesDir
+	}
+	if config.WordList != "" {
+		values["word-list"] = config.WordList
+	}
+	if config.WordListFormat != "" {
+		values["word-list-format"] = config.WordListFormat
+	}
+	if config.CsvColumn != "" {
+		values["csv-column"] = config.CsvColumn
+	}
+	if config.Concurrency != 0 {
+		values["concurrency"] = strconv.Itoa(config.Concurrency)
+	}
+	if config.Cache != "" {
+		values["cache"] = config.Cache
+	}
+	if config.NotFoundTTL != 0 {
+		values["not-found-ttl"] = config.NotFoundTTL.String()
+	}
+	if config.Timeout != 0 {
+		values["timeout"] = config.Timeout.String()
+	}
+	if config.Retries != nil {
+		values["retries"] = strconv.Itoa(*config.Retries)
+	}
+	if config.RetryBackoff != 0 {
+		values["retry-backoff"] = config.RetryBackoff.String()
+	}
+	if config.Lemmatize != nil {
+		values["lemmatize"] = strconv.FormatBool(*config.Lemmatize)
+	}
+	if config.Vocabulary != "" {
+		values["vocabulary"] = config.Vocabulary
+	}
+	if config.Preprocess.Dedupe {
+		values["dedupe"] = "true"
+	}
+	if config.Preprocess.Lowercase {
+		values["lowercase"] = "true"
+	}
+	if config.Preprocess.AlphaOnly {
+		values["alpha-only"] = "true"
+	}
+	if config.Preprocess.SkipProperNouns {
+		values["skip-proper-nouns"] = "true"
+	}
+	if config.Preprocess.FrequencyList != "" {
+		values["frequency-list"] = config.Preprocess.FrequencyList
+	}
+	if config.Preprocess.MinFrequency != 0 {
+		values["min-frequency"] = strconv.Itoa(config.Preprocess.MinFrequency)
+	}
+	if config.Preprocess.Top != 0 {
+		values["top"] = strconv.Itoa(config.Preprocess.Top)
+	}
+	if config.Preprocess.SkipStatus != nil {
+		values["skip-status"] = strings.Join(config.Preprocess.SkipStatus, ",")
+	}
+	if len(config.Dictionaries) > 0 {
+		var names []string
+		for _, dc := range config.Dictionaries {
+			names = append(names, dc.Name)
+			if dict.Dictionary(dc.Name) == dict.Collins && dc.Backend != "" {
+				values["collins-backend"] = dc.Backend
+			}
+		}
+		values["dicts"] = strings.Join(names, ",")
+	}
+	if config.Media.DownloadMp3 != nil {
+		values["download-mp3"] = strconv.FormatBool(*config.Media.DownloadMp3)
+	}
+	if config.Media.DownloadPic != nil {
+		values["download-pic"] = strconv.FormatBool(*config.Media.DownloadPic)
+	}
+	if config.Media.PicMaxSize != nil {
+		values["pic-max-size"] = strconv.Itoa(*config.Media.PicMaxSize)
+	}
+	if audio := config.Media.Audio; audio.Accents != nil {
+		values["audio-accents"] = strings.Join(audio.Accents, ",")
+	}
+	if audio := config.Media.Audio; audio.Voices != nil {
+		values["audio-voices"] = strings.Join(audio.Voices, ",")
+	}
+	if audio := config.Media.Audio; audio.Dicts != nil {
+		values["audio-dicts"] = strings.Join(audio.Dicts, ",")
+	}
+	if config.Media.Audio.Clips != nil {
+		values["audio-clips"] = strconv.Itoa(*config.Media.Audio.Clips)
+	}
+	if config.Media.Audio.Process != nil {
+		values["audio-process"] = strconv.FormatBool(*config.Media.Audio.Process)
+	}
+	if config.Media.Audio.Loudness != nil {
+		values["audio-loudness"] = strconv.FormatFloat(*config.Media.Audio.Loudness, 'g', -1, 64)
+	}
+	if config.Media.Audio.Silence != nil {
+		values["audio-silence"] = strconv.FormatFloat(*config.Media.Audio.Silence, 'g', -1, 64)
+	}
+	if config.Export.AnkiCsv != "" {
+		values["anki"] = "true"
+		values["anki-file"] = config.Export.AnkiCsv
+	}
+	if config.Export.AnkiApkg != "" {
+		values["anki-apkg"] = config.Export.AnkiApkg
+	}
+	if config.Export.AnkiDeck != "" {
+		values["anki-deck"] = config.Export.AnkiDeck
+	}
+	if config.Export.AnkiConnect != "" {
+		values["anki-connect"] = config.Export.AnkiConnect
+	}
+	if config.Export.AnkiConnectDryRun {
+		values["anki-connect-dry-run"] = "true"
+	}
+	if config.Export.AnkiCloze {
+		values["anki-cloze"] = "true"
+	}
+	if config.Export.Incremental {
+		values["incremental"] = "true"
+	}
+	return values
+}
+
+// applyConfig sets the flags of fs which are not given on the command line
+// to the values of config. A -timeout given on the command line also
+// replaces the timeouts of the dictionaries.
+func applyConfig(fs *flag.FlagSet, config *Config) error {
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	for name, value := range config.flagValues() {
+		if given[name] || fs.Lookup(name) == nil {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("cannot set -%v to '%v': %v", name, value, err)
+		}
+	}
+	if given["timeout"] {
+		for i := range config.Dictionaries {
+			config.Dictionaries[i].Timeout = 0
+		}
+	}
+	return nil
+}
+
+// dictConfig returns the config of the dictionary, empty if not configured.
+func (config *Config) dictConfig(dictionary dict.Dictionary) DictConfig {
+	for _, dc := range config.Dictionaries {
+		if dict.Dictionary(dc.Name) == dictionary {
+			return dc
+		}
+	}
+	return DictConfig{Name: string(dictionary)}
+}
+
+// dictDir is the directory of the cache and media of the dictionary.
+func dictDir(dictionary dict.Dictionary) string {
+	return filepath.Join(*dataDir, string(dictionary))
+}
+
+// newDict creates the dictionary with the options of its config.
+func newDict(dc DictConfig) (dict.Dict, error) {
+	var opts []dict.Option
+	if dc.BaseUrl != "" {
+		opts = append(opts, dict.WithBaseUrl(dc.BaseUrl))
+	}
+	if dc.UserAgent != "" {
+		opts = append(opts, dict.WithUserAgent(dc.UserAgent))
+	}
+	if dc.Proxy != "" {
+		proxy, err := url.Parse(dc.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy of %v: %v", dc.Name, err)
+		}
+		opts = append(opts, dict.WithProxy(proxy))
+	}
+
+	for key, value := range dc.Params {
+		opts = append(opts, dict.WithParam(key, value))
+	}
+	if dict.Dictionary(dc.Name) == dict.Collins {
+		opts = append(opts,
+			dict.WithParam(collins.ParamBackend, *collinsBackend),
+			dict.WithParam(collins.ParamPagesDir, filepath.Join(dictDir(dict.Collins), "pages")),
+		)
+		if s := dc.Selenium; s != nil {
+			if s.SeleniumPath != "" {
+				opts = append(opts, dict.WithParam(collins.ParamSeleniumPath, s.SeleniumPath))
+			}
+			if s.ChromeDriverPath != "" {
+				opts = append(opts, dict.WithParam(collins.ParamChromeDriverPath, s.ChromeDriverPath))
+			}
+			if s.ChromePath != "" {
+				opts = append(opts, dict.WithParam(collins.ParamChromePath, s.ChromePath))
+			}
+			if s.Port != 0 {
+				opts = append(opts, dict.WithParam(collins.ParamSeleniumPort, strconv.Itoa(s.Port)))
+			}
+			if s.Proxy != nil {
+				opts = append(opts, dict.WithParam(collins.ParamSeleniumProxy, *s.Proxy))
+			}
+		}
+	}
+	return dict.New(dict.Dictionary(dc.Name), opts...)
+}
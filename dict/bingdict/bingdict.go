@@ -1,10 +1,13 @@
 package bingdict
 
 import (
+	"context"
+	_ "embed"
 	"encoding/json"
 	"fmt"
 	"github.com/PuerkitoBio/goquery"
 	"github.com/gocolly/colly/v2"
+	"html/template"
 	"log"
 	"net"
 	"net/http"
@@ -14,8 +17,24 @@ import (
 	"word-downloader/dict"
 )
 
+const defaultBaseUrl = "https://cn.bing.com"
+
+func init() {
+	dict.Register(dict.Provider{
+		Name:         dict.BingDict,
+		DisplayName:  "必应词典",
+		Priority:     40,
+		Capabilities: dict.Capabilities{Audio: true, Pictures: true, Bilingual: true},
+		New: func(opts ...dict.Option) (dict.Dict, error) {
+			return NewBingDict(opts...), nil
+		},
+	})
+}
+
 type bingDict struct {
-	transport *http.Transport
+	baseUrl    string
+	httpClient *http.Client
+	userAgent  string
 }
 
 type Word struct {
@@ -48,6 +67,54 @@ func (w Word) Mp3() []string {
 
 var _ dict.Word = Word{}
 
+func (w Word) Audios() []dict.Audio {
+	var audios []dict.Audio
+	if w.Audio.USAudio != "" {
+		audios = append(audios, dict.Audio{Url: w.Audio.USAudio, Accent: dict.AccentUs})
+	}
+	if w.Audio.UKAudio != "" {
+		audios = append(audios, dict.Audio{Url: w.Audio.UKAudio, Accent: dict.AccentUk})
+	}
+	return audios
+}
+
+func (w Word) PictureUrls() []string {
+	return w.Pictures
+}
+
+func (w Word) ExampleSentences() []string {
+	var sentences []string
+	for _, example := range w.Examples {
+		for _, sentence := range example.Sentences() {
+			sentences = append(sentences, sentence.En)
+		}
+	}
+	return sentences
+}
+
+// BasicDefinition is the simple definition, a line for each part of speech.
+func (w Word) BasicDefinition() []string {
+	var lines []string
+	for _, def := range w.Defs {
+		if def.PartOfSpeech != "simple-def" {
+			continue
+		}
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(def.Raw))
+		if err != nil {
+			return nil
+		}
+		doc.Find(".simple-def li").Each(func(i int, selection *goquery.Selection) {
+			pos := selection.Find(".pos")
+			// the definitions of the web are not a part of speech
+			if pos.HasClass("web") {
+				return
+			}
+			lines = append(lines, strings.TrimSpace(pos.Text()+" "+selection.Find(".def").Text()))
+		})
+	}
+	return lines
+}
+
 type Definition struct {
 	PartOfSpeech string
 	Def          []SubDefinition
@@ -75,14 +142,23 @@ type Example struct {
 	Audio  string
 }
 
-func NewBingDict() *bingDict {
-	return &bingDict{transport: &http.Transport{
-		Proxy:               nil,
-		DialContext:         (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
-		MaxIdleConns:        256,
-		MaxIdleConnsPerHost: 256,
-		IdleConnTimeout:     time.Minute * 10,
-	},
+func NewBingDict(opts ...dict.Option) *bingDict {
+	options := dict.NewOptions(dict.Options{BaseUrl: defaultBaseUrl}, opts...)
+	if options.HttpClient == nil {
+		options.HttpClient = &http.Client{
+			Transport: &http.Transport{
+				Proxy:               http.ProxyURL(options.Proxy),
+				DialContext:         (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+				MaxIdleConns:        256,
+				MaxIdleConnsPerHost: 256,
+				IdleConnTimeout:     time.Minute * 10,
+			},
+		}
+	}
+	return &bingDict{
+		baseUrl:    strings.TrimSuffix(options.BaseUrl, "/"),
+		httpClient: options.HttpClient,
+		userAgent:  options.UserAgent,
 	}
 }
 
@@ -96,26 +172,78 @@ func (bing *bingDict) Parse(wordJson []byte) (dict.Word, error) {
 }
 
 func (w Word) Pronunciation() string {
-	return ""
+	var prs []string
+	for _, pr := range []string{w.Audio.PronunciationUS, w.Audio.PronunciationUK} {
+		pr = strings.Join(strings.Fields(pr), " ")
+		if pr != "" {
+			prs = append(prs, pr)
+		}
+	}
+	return strings.Join(prs, " | ")
 }
 
+//go:embed templates/bing-dict.html
+var templateText string
+
+var tmpl = dict.NewTemplate(string(dict.BingDict), templateText, template.FuncMap{
+	"sectionName": sectionName,
+})
+
 func (w Word) DefinitionHtml(showWord bool) string {
-	return ""
+	return tmpl.Render(struct {
+		Word
+		ShowWord bool
+	}{w, showWord})
+}
+
+// section names of the definitions
+var definitionNames = map[string]string{
+	"simple-def": "简明释义",
+	"auth":       "权威英汉双解",
+	"homo":       "英汉",
+	"cross":      "英英",
+}
+
+// sectionName is the name of the section of the definition pos.
+func sectionName(pos string) string {
+	if name, ok := definitionNames[pos]; ok {
+		return name
+	}
+	return pos
+}
+
+// Sentence is a bilingual example sentence.
+type Sentence struct {
+	En string
+	Cn string
+}
+
+// Sentences parses the bilingual sentences of the raw example.
+func (e Example) Sentences() []Sentence {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(e.Raw))
+	if err != nil {
+		return nil
+	}
+	var sentences []Sentence
+	doc.Find(".se_li").Each(func(i int, selection *goquery.Selection) {
+		en := strings.Join(strings.Fields(selection.Find(".sen_en").Text()), " ")
+		cn := strings.TrimSpace(selection.Find(".sen_cn").Text())
+		if en != "" {
+			sentences = append(sentences, Sentence{En: en, Cn: cn})
+		}
+	})
+	return sentences
 }
 
 func (bing *bingDict) Lookup(word string) (dict.Word, error) {
+	return bing.LookupContext(context.Background(), word)
+}
+
+func (bing *bingDict) LookupContext(ctx context.Context, word string) (dict.Word, error) {
 	col := colly.NewCollector(
-		colly.UserAgent("Mozilla/5.0 (Windows NT 6.1) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/41.0.2228.0 Safari/537.36"),
+		colly.UserAgent(bing.userAgent),
 	)
-	col.SetClient(&http.Client{
-		Transport: &http.Transport{
-			Proxy:               nil,
-			DialContext:         (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
-			MaxIdleConns:        256,
-			MaxIdleConnsPerHost: 256,
-			IdleConnTimeout:     time.Minute * 10,
-		},
-	})
+	col.SetClient(dict.ContextClient(ctx, bing.httpClient))
 
 	out := Word{}
 
@@ -154,6 +282,17 @@ func (bing *bingDict) Lookup(word string) (dict.Word, error) {
 		}
 	})
 
+	// image thumbnails
+	col.OnHTML(".img_area img", func(element *colly.HTMLElement) {
+		src := element.Attr("src")
+		if src == "" || strings.HasPrefix(src, "data:") {
+			src = element.Attr("data-src")
+		}
+		if src != "" && !strings.HasPrefix(src, "data:") {
+			out.Pictures = append(out.Pictures, element.Request.AbsoluteURL(src))
+		}
+	})
+
 	// simple definition
 	var simpleDef Definition
 	var plural string
@@ -236,12 +375,18 @@ func (bing *bingDict) Lookup(word string) (dict.Word, error) {
 
 	urlEncodedWord := url.QueryEscape(word)
 	searchUrl := fmt.Sprintf(
-		"https://cn.bing.com/dict/search?q=%v&qs=n&form=Z9LH5&sp=-1&pq=kes&sc=4-3&sk=",
+		"%v/dict/search?q=%v&qs=n&form=Z9LH5&sp=-1&pq=kes&sc=4-3&sk=",
+		bing.baseUrl,
 		urlEncodedWord,
 	)
+	var statusCode int
+	col.OnError(func(response *colly.Response, err error) {
+		statusCode = response.StatusCode
+	})
+
 	err := col.Visit(searchUrl)
 	if err != nil {
-		return Word{}, err
+		return Word{}, dict.ClassifyError(statusCode, err)
 	}
 
 	if simpleDef.Raw != "" {
@@ -251,7 +396,10 @@ func (bing *bingDict) Lookup(word string) (dict.Word, error) {
 	}
 
 	if out.W == "" {
-		return Word{}, dict.ErrNotFound
+		return Word{}, dict.NotFound("no #headword on the page")
+	}
+	if len(out.Defs) == 0 {
+		return Word{}, &dict.Error{Kind: dict.ErrParse, Err: fmt.Errorf("no definition of '%v'", out.W)}
 	}
 
 	return out, nil
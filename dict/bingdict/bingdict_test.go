@@ -2,10 +2,75 @@ package bingdict
 
 import (
 	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
 	"testing"
+	"word-downloader/dict"
+	"word-downloader/dict/dicttest"
 )
 
+func newTestDict(t *testing.T) *bingDict {
+	srv := dicttest.NewServer(t, func(r *http.Request) string {
+		return r.URL.Query().Get("q")
+	})
+	return NewBingDict(dict.WithBaseUrl(srv.URL), dict.WithHttpClient(srv.Client()))
+}
+
+func TestBingDict_LookupOffline(t *testing.T) {
+	bing := newTestDict(t)
+	word, err := bing.Lookup("kestrel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dicttest.Golden(t, "kestrel", word)
+}
+
+func TestWord_DefinitionHtml(t *testing.T) {
+	bing := newTestDict(t)
+	word, err := bing.Lookup("kestrel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr := word.Pronunciation(); pr != "美 [ˈkestrəl] | 英 [ˈkestrəl]" {
+		t.Errorf("unexpected pronunciation: %v", pr)
+	}
+	dicttest.GoldenText(t, "kestrel.golden.html", word.DefinitionHtml(true)+"\n")
+}
+
+func TestWord_Data(t *testing.T) {
+	word, err := newTestDict(t).Lookup("kestrel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	audios := dict.Audios(word)
+	if len(audios) != 2 || audios[0].Accent != dict.AccentUs || audios[1].Accent != dict.AccentUk {
+		t.Errorf("unexpected audios: %v", audios)
+	}
+	// the definitions of the web are left out
+	if basic := dict.BasicDefinition(word); len(basic) != 1 || basic[0] != "n. 红隼" {
+		t.Errorf("unexpected basic definition: %q", basic)
+	}
+	if pics := dict.Pictures(word); len(pics) != 1 || pics[0] != "https://cn.bing.com/th?id=OIP.kestrel01&w=80&h=80&c=8&rs=1&qlt=90" {
+		t.Errorf("unexpected pictures: %q", pics)
+	}
+	for _, sentence := range dict.ExampleSentences(word) {
+		if !strings.Contains(strings.ToLower(sentence), "kestrel") {
+			t.Errorf("unexpected example: %q", sentence)
+		}
+	}
+}
+
+func TestBingDict_LookupNotFound(t *testing.T) {
+	bing := newTestDict(t)
+	_, err := bing.Lookup("asdfghjk")
+	if !errors.Is(err, dict.ErrNotFound) {
+		t.Fatalf("want ErrNotFound, got: %v", err)
+	}
+}
+
 func TestBingDict_Lookup(t *testing.T) {
+	dicttest.SkipUnlessLive(t)
 	dict := NewBingDict()
 	word, err := dict.Lookup("kestrel")
 	if err != nil {
@@ -14,3 +79,11 @@ func TestBingDict_Lookup(t *testing.T) {
 	buf, _ := json.MarshalIndent(word, "", " ")
 	t.Logf("%v", string(buf))
 }
+
+// TestRecordPages saves the fixtures from cn.bing.com, with -record.
+func TestRecordPages(t *testing.T) {
+	dicttest.RecordPages(t, defaultBaseUrl, map[string]string{
+		"kestrel":  "/dict/search?q=kestrel",
+		"asdfghjk": "/dict/search?q=asdfghjk",
+	})
+}
@@ -1,14 +1,21 @@
 package collins
 
 import (
+	"context"
+	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/tebeka/selenium"
-	"github.com/tebeka/selenium/chrome"
-	"io/ioutil"
-	"os"
-	"path/filepath"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly/v2"
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 	"word-downloader/dict"
 )
 
@@ -38,85 +45,59 @@ func (w Word) Pronunciation() string {
 	return ""
 }
 
+//go:embed templates/collins.html
+var templateText string
+
+var tmpl = dict.NewTemplate(string(dict.Collins), templateText, template.FuncMap{
+	"pos": partOfSpeech,
+})
+
 func (w Word) DefinitionHtml(showWord bool) string {
-	sb := strings.Builder{}
+	return tmpl.Render(struct {
+		Word
+		ShowWord bool
+	}{w, showWord})
+}
 
-	sb.WriteString(`<div class="word-content">`)
-	sb.WriteString(fmt.Sprintf(`<div class="dict-name">%v</div>`, w.Type().Name()))
+var _ dict.Word = Word{}
 
-	if showWord {
-		sb.WriteString(`<div class="this-word">`)
-		sb.WriteString(w.W)
-		sb.WriteString(`</div>`)
+func (w Word) ExampleSentences() []string {
+	var sentences []string
+	for _, def := range w.Defs {
+		for _, example := range def.Examples {
+			if text := strings.TrimSpace(example.Text); text != "" {
+				sentences = append(sentences, text)
+			}
+		}
 	}
+	return sentences
+}
 
-	if len(w.Defs) > 1 {
-		for i, def := range w.Defs {
-			sb.WriteString(def.Html(i + 1))
+// BasicDefinition is the first definition of each part of speech.
+func (w Word) BasicDefinition() []string {
+	var lines []string
+	seen := map[string]bool{}
+	for _, def := range w.Defs {
+		if seen[def.PartOfSpeech] || def.Def == "" {
+			continue
 		}
-	} else if len(w.Defs) == 1 {
-		sb.WriteString(w.Defs[0].Html(0))
+		seen[def.PartOfSpeech] = true
+		lines = append(lines, strings.TrimSpace(def.PartOfSpeech+" "+def.Def))
 	}
-
-	sb.WriteString(`</div>`)
-	return sb.String()
+	return lines
 }
 
-var _ dict.Word = Word{}
-
 type Definition struct {
 	PartOfSpeech string
 	Def          string
 	Examples     []Example
 }
 
-func (d Definition) Html(serialNo int) string {
-	sb := strings.Builder{}
-	sb.WriteString(`<div class="definitions">`)
-
-	sb.WriteString(`<div class="pos">`)
-	if serialNo > 0 {
-		sb.WriteString(fmt.Sprintf("%v. ", serialNo) + partOfSpeech(d.PartOfSpeech))
-	} else {
-		sb.WriteString(partOfSpeech(d.PartOfSpeech))
-	}
-	sb.WriteString(`</div>`)
-
-	sb.WriteString(`<div class="collins-def">`)
-	sb.WriteString(d.Def)
-	sb.WriteString(`</div>`)
-
-	for _, example := range d.Examples {
-		sb.WriteString(`<div class="collins-use-examples">`)
-		sb.WriteString(example.Html())
-		sb.WriteString("</div>")
-	}
-
-	sb.WriteString("</div>")
-	return sb.String()
-}
-
 type SubDefinition struct {
 	Def      string
 	Examples []Example
 }
 
-func (s SubDefinition) Html() string {
-	sb := strings.Builder{}
-	sb.WriteString(fmt.Sprintf(`<div class="sub-def-content">`))
-	sb.WriteString(`<div class="sub-def">`)
-	sb.WriteString(s.Def)
-	sb.WriteString(`</div>`)
-
-	sb.WriteString(`<div class="use-examples">`)
-	for _, exp := range s.Examples {
-		sb.WriteString(exp.Html())
-	}
-	sb.WriteString("</div>")
-	sb.WriteString("</div>")
-	return sb.String()
-}
-
 type Audio struct {
 	Syllables     string
 	Pronunciation string
@@ -127,13 +108,88 @@ type Example struct {
 	Text string
 }
 
-func (e Example) Html() string {
-	return fmt.Sprintf(`<div class=use-example>// %v</div>`, e.Text)
+const defaultBaseUrl = "https://www.collinsdictionary.com"
+
+func init() {
+	dict.Register(dict.Provider{
+		Name:         dict.Collins,
+		DisplayName:  "Collins",
+		Priority:     10,
+		Capabilities: dict.Capabilities{},
+		New:          newFromParams,
+	})
 }
 
+// The params of the collins provider, see dict.WithParam.
+const (
+	// ParamBackend is http, selenium or fallback
+	ParamBackend          = "backend"
+	ParamSeleniumPath     = "selenium_path"
+	ParamChromeDriverPath = "chromedriver_path"
+	ParamChromePath       = "chrome_path"
+	ParamSeleniumPort     = "selenium_port"
+	// ParamSeleniumProxy is the proxy of the browser, empty for none
+	ParamSeleniumProxy = "selenium_proxy"
+	ParamPagesDir      = "pages_dir"
+)
+
+// newFromParams creates the dictionary with the backend and the
+// selenium config given by the params.
+func newFromParams(opts ...dict.Option) (dict.Dict, error) {
+	params := dict.NewOptions(dict.Options{}, opts...).Params
+	config := DefaultSeleniumConfig()
+	for key, value := range params {
+		switch key {
+		case ParamBackend:
+		case ParamSeleniumPath:
+			config.SeleniumPath = value
+		case ParamChromeDriverPath:
+			config.ChromeDriverPath = value
+		case ParamChromePath:
+			config.ChromePath = value
+		case ParamSeleniumPort:
+			port, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid collins param %v '%v'", key, value)
+			}
+			config.Port = port
+		case ParamSeleniumProxy:
+			config.Proxy = value
+		case ParamPagesDir:
+			config.PagesDir = value
+		default:
+			return nil, fmt.Errorf("unknown collins param '%v'", key)
+		}
+	}
+	collins, err := NewDictWithBackend(Backend(params[ParamBackend]), config, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return collins, nil
+}
+
+// Backend is the way collins pages are fetched.
+type Backend string
+
+const (
+	// HttpBackend fetches the pages with plain http requests.
+	HttpBackend Backend = "http"
+	// SeleniumBackend renders the pages in a browser driven by selenium.
+	SeleniumBackend Backend = "selenium"
+	// FallbackBackend uses http, and selenium once http is blocked.
+	FallbackBackend Backend = "fallback"
+)
+
+type pageFunc func(ctx context.Context, url string) (string, error)
+
 type collinsDict struct {
-	service *selenium.Service
-	wd      selenium.WebDriver
+	baseUrl    string
+	httpClient *http.Client
+	userAgent  string
+	// fetchPage returns the html source of url
+	fetchPage pageFunc
+	// browser is the selenium backend, nil for http
+	browser *seleniumBackend
 }
 
 func (collins *collinsDict) Parse(wordJson []byte) (dict.Word, error) {
@@ -142,95 +198,169 @@ func (collins *collinsDict) Parse(wordJson []byte) (dict.Word, error) {
 	return word, err
 }
 
-func NewDict() *collinsDict {
-	os.MkdirAll(filepath.Join(string(dict.Collins), "pages"), 0755)
-
-	const (
-		seleniumPath     = "selenium/selenium-server.jar"
-		chromeDriverPath = "selenium/chromedriver"
-		port             = 8080
-	)
-	opts := []selenium.ServiceOption{
-		selenium.ChromeDriver(chromeDriverPath), // Specify the path to GeckoDriver in order to use Firefox.
-		//selenium.Output(os.Stderr),              // Output debug information to STDERR.
+// NewDict returns a collins dictionary using the http backend.
+func NewDict(opts ...dict.Option) *collinsDict {
+	options := dict.NewOptions(dict.Options{BaseUrl: defaultBaseUrl}, opts...)
+	if options.HttpClient == nil {
+		options.HttpClient = &http.Client{
+			Transport: &http.Transport{
+				Proxy:               http.ProxyURL(options.Proxy),
+				DialContext:         (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+				MaxIdleConns:        256,
+				MaxIdleConnsPerHost: 256,
+				IdleConnTimeout:     time.Minute * 10,
+			},
+		}
 	}
-	service, err := selenium.NewSeleniumService(seleniumPath, port, opts...)
-	if err != nil {
-		panic(err) // panic is used only as an example and is not otherwise recommended.
+	collins := &collinsDict{
+		baseUrl:    strings.TrimSuffix(options.BaseUrl, "/"),
+		httpClient: options.HttpClient,
+		userAgent:  options.UserAgent,
 	}
+	collins.fetchPage = collins.httpPage
+	return collins
+}
 
-	// Connect to the WebDriver instance running locally.
-	//caps := selenium.Capabilities{"browserName": "firefox"}
-	caps := selenium.Capabilities{"browserName": "chrome"}
-	caps.AddChrome(chrome.Capabilities{
-		Path: "selenium/chrome-linux/chrome",
-	})
-	caps.AddProxy(selenium.Proxy{
-		Type: selenium.Manual,
-		HTTP: "http://127.0.0.1:8888",
-		SSL:  "http://127.0.0.1:8888",
-	})
-	wd, err := selenium.NewRemote(caps, fmt.Sprintf("http://localhost:%d/wd/hub", port))
-	if err != nil {
-		panic(err)
+// NewDictWithBackend returns a collins dictionary using backend. The
+// selenium backend is started at once, the fallback one when first needed.
+func NewDictWithBackend(backend Backend, config SeleniumConfig, opts ...dict.Option) (*collinsDict, error) {
+	collins := NewDict(opts...)
+	browser := &seleniumBackend{config: config}
+	switch backend {
+	case HttpBackend, "":
+	case SeleniumBackend:
+		if err := browser.start(); err != nil {
+			return nil, err
+		}
+		collins.fetchPage = browser.page
+		collins.browser = browser
+	case FallbackBackend:
+		collins.fetchPage = fallback(collins.httpPage, browser.page)
+		collins.browser = browser
+	default:
+		return nil, fmt.Errorf("unknown collins backend '%v'", backend)
 	}
+	return collins, nil
+}
 
-	return &collinsDict{
-		service: service,
-		wd:      wd,
+// fallback returns a pageFunc using second for the requests first is blocked from.
+func fallback(first pageFunc, second pageFunc) pageFunc {
+	return func(ctx context.Context, url string) (string, error) {
+		page, err := first(ctx, url)
+		if errors.Is(err, dict.ErrBlocked) {
+			log.Printf("collins: %v, fall back to selenium", err)
+			return second(ctx, url)
+		}
+		return page, err
 	}
 }
 
+// Close quits the browser of the selenium backend, if it is started.
+func (collins *collinsDict) Close() error {
+	if collins.browser == nil {
+		return nil
+	}
+	return collins.browser.close()
+}
+
 func (collins *collinsDict) Type() dict.Dictionary {
 	return dict.Collins
 }
 
-func (collins *collinsDict) Lookup(word string) (dict.Word, error) {
-	if err := collins.wd.Get("https://www.collinsdictionary.com/dictionary/english/" + word); err != nil {
-		return nil, err
+func (collins *collinsDict) httpPage(ctx context.Context, url string) (string, error) {
+	col := colly.NewCollector(
+		colly.UserAgent(collins.userAgent),
+	)
+	col.SetClient(dict.ContextClient(ctx, collins.httpClient))
+
+	var pageSource string
+	col.OnResponse(func(response *colly.Response) {
+		pageSource = string(response.Body)
+	})
+
+	col.OnRequest(func(r *colly.Request) {
+		r.Headers.Add("accept", "*/*")
+	})
+
+	var statusCode int
+	col.OnError(func(response *colly.Response, err error) {
+		statusCode = response.StatusCode
+	})
+
+	err := col.Visit(url)
+	if statusCode == http.StatusNotFound {
+		return "", dict.NotFound("page not found (404)")
+	}
+	if err != nil {
+		return "", dict.ClassifyError(statusCode, err)
 	}
+	return pageSource, nil
+}
 
-	id := fmt.Sprintf("%v__1", strings.ToLower(word))
-	content, err := collins.wd.FindElement(selenium.ByID, id)
+func (collins *collinsDict) Lookup(word string) (dict.Word, error) {
+	return collins.LookupContext(context.Background(), word)
+}
+
+func (collins *collinsDict) LookupContext(ctx context.Context, word string) (dict.Word, error) {
+	pageSource, err := collins.fetchPage(ctx, collins.baseUrl+"/dictionary/english/"+url.PathEscape(word))
 	if err != nil {
-		return nil, dict.ErrNotFound
+		return nil, dict.ClassifyError(0, err)
 	}
 
-	elements, err := content.FindElements(selenium.ByClassName, "hom")
+	out, err := parsePage(word, pageSource)
 	if err != nil {
 		return nil, err
 	}
-	out := Word{}
-	out.W = word
-	for _, element := range elements {
-		def := Definition{}
-		posElement, err := element.FindElement(selenium.ByClassName, "pos")
-		if err == nil {
-			def.PartOfSpeech, _ = posElement.Text()
+	return out, nil
+}
+
+// parsePage extracts the definitions of word from a collins page.
+func parsePage(word string, pageSource string) (Word, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(pageSource))
+	if err != nil {
+		return Word{}, err
+	}
+
+	id := fmt.Sprintf("%v__1", strings.ToLower(word))
+	content := doc.Find(fmt.Sprintf(`[id="%v"]`, id))
+	headword := word
+	if content.Length() == 0 {
+		// the page of an inflected form, e.g. running, is the entry of its
+		// lemma, whose id is run__1
+		content = doc.Find(`.dictentry [id$="__1"]`).First()
+		if content.Length() == 0 {
+			return Word{}, dict.NotFound(fmt.Sprintf("no #%v on the page", id))
 		}
+		headword = strings.TrimSuffix(content.AttrOr("id", ""), "__1")
+	}
 
-		defElement, err := element.FindElement(selenium.ByClassName, "def")
-		if err == nil {
-			def.Def, _ = defElement.Text()
+	out := Word{}
+	out.W = headword
+	content.Find(".hom").Each(func(_ int, element *goquery.Selection) {
+		def := Definition{
+			PartOfSpeech: cleanText(element.Find(".pos").First().Text()),
+			Def:          cleanText(element.Find(".def").First().Text()),
 		}
-		examples, err := element.FindElements(selenium.ByClassName, "type-example")
-		for _, e := range examples {
-			exampleStr, _ := e.Text()
+		element.Find(".type-example").Each(func(_ int, e *goquery.Selection) {
+			exampleStr := cleanText(e.Text())
 			if exampleStr != "" {
 				def.Examples = append(def.Examples, Example{Text: exampleStr})
 			}
-		}
+		})
 		out.Defs = append(out.Defs, def)
-	}
-
-	pageSource, err := collins.wd.PageSource()
-	if err == nil {
-		ioutil.WriteFile(filepath.Join(string(dict.Collins), "pages", word+".html"), []byte(pageSource), 0644)
+	})
+	if len(out.Defs) == 0 {
+		return Word{}, &dict.Error{Kind: dict.ErrParse, Err: fmt.Errorf("no definition of '%v'", word)}
 	}
 
 	return out, nil
 }
 
+// cleanText collapses whitespace the way a browser renders text.
+func cleanText(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
 func partOfSpeech(pos string) string {
 	return strings.ToLower(pos)
 }
@@ -1,15 +1,131 @@
 package collins
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/tebeka/selenium"
 	"github.com/tebeka/selenium/chrome"
 	"io/ioutil"
+	"net/http"
 	"os"
+	"path"
+	"strings"
 	"testing"
+	"word-downloader/dict"
+	"word-downloader/dict/dicttest"
 )
 
+// newTestDict returns a collins dictionary which fetches pages from the
+// fixture server.
+func newTestDict(t *testing.T) *collinsDict {
+	srv := dicttest.NewServer(t, func(r *http.Request) string {
+		return path.Base(r.URL.Path)
+	})
+	return NewDict(dict.WithBaseUrl(srv.URL), dict.WithHttpClient(srv.Client()))
+}
+
+func TestCollinsDict_LookupOffline(t *testing.T) {
+	collins := newTestDict(t)
+	for _, word := range []string{"exhort", "regret"} {
+		t.Run(word, func(t *testing.T) {
+			w, err := collins.Lookup(word)
+			if err != nil {
+				t.Fatalf("cannot lookup: %v", err)
+			}
+			dicttest.Golden(t, word, w)
+		})
+	}
+}
+
+func TestCollinsDict_LookupInflected(t *testing.T) {
+	collins := newTestDict(t)
+	// the page of exhorted is the entry of exhort
+	w, err := collins.Lookup("exhorted")
+	if err != nil {
+		t.Fatalf("cannot lookup: %v", err)
+	}
+	if w.Word() != "exhort" {
+		t.Fatalf("want the head word exhort, got: %v", w.Word())
+	}
+}
+
+func TestCollinsDict_LookupNotFound(t *testing.T) {
+	collins := newTestDict(t)
+	_, err := collins.Lookup("asdfghjk")
+	if !errors.Is(err, dict.ErrNotFound) {
+		t.Fatalf("want ErrNotFound, got: %v", err)
+	}
+}
+
+func TestCollinsDict_LookupBlocked(t *testing.T) {
+	collins := newTestDict(t)
+	_, err := collins.Lookup("blocked")
+	if !errors.Is(err, dict.ErrBlocked) {
+		t.Fatalf("want ErrBlocked, got: %v", err)
+	}
+}
+
+func TestCollinsDict_Fallback(t *testing.T) {
+	collins := newTestDict(t)
+	var fallbackUrls []string
+	// exhort is blocked by the first backend, the others are served by http
+	first := func(ctx context.Context, url string) (string, error) {
+		if path.Base(url) == "exhort" {
+			return collins.httpPage(ctx, strings.TrimSuffix(url, "exhort")+"blocked")
+		}
+		return collins.httpPage(ctx, url)
+	}
+	collins.fetchPage = fallback(first, func(ctx context.Context, url string) (string, error) {
+		fallbackUrls = append(fallbackUrls, url)
+		buf, err := ioutil.ReadFile("testdata/exhort.html")
+		return string(buf), err
+	})
+
+	if _, err := collins.Lookup("regret"); err != nil {
+		t.Fatalf("cannot lookup: %v", err)
+	}
+	if _, err := collins.Lookup("asdfghjk"); !errors.Is(err, dict.ErrNotFound) {
+		t.Fatalf("want ErrNotFound, got: %v", err)
+	}
+	if len(fallbackUrls) != 0 {
+		t.Fatalf("unexpected fallback: %v", fallbackUrls)
+	}
+
+	w, err := collins.Lookup("exhort")
+	if err != nil {
+		t.Fatalf("cannot lookup: %v", err)
+	}
+	if len(fallbackUrls) != 1 || path.Base(fallbackUrls[0]) != "exhort" {
+		t.Fatalf("want fallback for exhort, got: %v", fallbackUrls)
+	}
+	if w.Word() != "exhort" {
+		t.Fatalf("want exhort, got: %v", w.Word())
+	}
+}
+
+func TestNewDictWithBackend(t *testing.T) {
+	if _, err := NewDictWithBackend("chrome", DefaultSeleniumConfig()); err == nil {
+		t.Fatalf("want error of unknown backend")
+	}
+	collins, err := NewDictWithBackend(FallbackBackend, DefaultSeleniumConfig())
+	if err != nil {
+		t.Fatalf("selenium should not be started yet: %v", err)
+	}
+	if collins.Type() != dict.Collins {
+		t.Fatalf("want collins, got: %v", collins.Type())
+	}
+	// the browser which is not started is not stopped
+	if err := collins.Close(); err != nil {
+		t.Fatalf("cannot close: %v", err)
+	}
+	if _, err := collins.browser.page(context.Background(), "https://www.collinsdictionary.com/dictionary/english/exhort"); err == nil {
+		t.Fatalf("want error of a closed browser")
+	}
+}
+
 func TestCollinsDict_Lookup(t *testing.T) {
+	dicttest.SkipUnlessLive(t)
 	const (
 		// These paths will be different on your system.
 		seleniumPath     = "selenium/selenium-server.jar"
@@ -60,10 +176,53 @@ func TestCollinsDict_Lookup(t *testing.T) {
 }
 
 func TestCollinsDict_Lookup2(t *testing.T) {
-	dict := NewDict()
+	dicttest.SkipUnlessLive(t)
+	dict, err := NewDictWithBackend(SeleniumBackend, DefaultSeleniumConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
 	word, err := dict.Lookup("exhort")
 	if err != nil {
 		t.Fatal(err)
 	}
 	t.Logf("success: %v", word.DefinitionHtml(false))
 }
+
+func TestNewFromParams(t *testing.T) {
+	d, err := dict.New(dict.Collins, dict.WithParam(ParamBackend, string(FallbackBackend)), dict.WithParam(ParamSeleniumPort, "4444"))
+	if err != nil {
+		t.Fatalf("cannot create: %v", err)
+	}
+	if d.Type() != dict.Collins {
+		t.Fatalf("want collins, got: %v", d.Type())
+	}
+	// the browser has no proxy unless configured
+	if proxy := d.(*collinsDict).browser.config.Proxy; proxy != "" {
+		t.Fatalf("want no proxy by default, got: %v", proxy)
+	}
+	d, err = dict.New(dict.Collins, dict.WithParam(ParamBackend, string(FallbackBackend)), dict.WithParam(ParamSeleniumProxy, "http://127.0.0.1:8888"))
+	if err != nil {
+		t.Fatalf("cannot create: %v", err)
+	}
+	if proxy := d.(*collinsDict).browser.config.Proxy; proxy != "http://127.0.0.1:8888" {
+		t.Fatalf("want the configured proxy, got: %v", proxy)
+	}
+	if _, err := dict.New(dict.Collins, dict.WithParam("selenium_host", "localhost")); err == nil {
+		t.Fatalf("want error of unknown param")
+	}
+	if _, err := dict.New(dict.Collins, dict.WithParam(ParamSeleniumPort, "http")); err == nil {
+		t.Fatalf("want error of invalid port")
+	}
+}
+
+// TestRecordPages saves the fixtures from collinsdictionary.com, with
+// -record. The site may block the requests which are not of a browser,
+// the page of the selenium backend can be saved instead.
+func TestRecordPages(t *testing.T) {
+	dicttest.RecordPages(t, defaultBaseUrl, map[string]string{
+		"regret":   "/dictionary/english/regret",
+		"exhort":   "/dictionary/english/exhort",
+		"exhorted": "/dictionary/english/exhorted",
+		"asdfghjk": "/dictionary/english/asdfghjk",
+	})
+}
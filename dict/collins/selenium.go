@@ -0,0 +1,133 @@
+package collins
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"word-downloader/dict"
+
+	"github.com/tebeka/selenium"
+	"github.com/tebeka/selenium/chrome"
+)
+
+// SeleniumConfig locates the selenium server and the browser it drives.
+type SeleniumConfig struct {
+	SeleniumPath     string
+	ChromeDriverPath string
+	ChromePath       string
+	Port             int
+	// Proxy is used by the browser for http and https, empty for none
+	Proxy string
+	// PagesDir keeps the source of each looked up page, empty to not keep them
+	PagesDir string
+}
+
+func DefaultSeleniumConfig() SeleniumConfig {
+	return SeleniumConfig{
+		SeleniumPath:     "selenium/selenium-server.jar",
+		ChromeDriverPath: "selenium/chromedriver",
+		ChromePath:       "selenium/chrome-linux/chrome",
+		Port:             8080,
+		PagesDir:         filepath.Join(string(dict.Collins), "pages"),
+	}
+}
+
+// seleniumBackend renders the pages in a browser, it is started on first use.
+type seleniumBackend struct {
+	config  SeleniumConfig
+	once    sync.Once
+	err     error
+	service *selenium.Service
+	// mu guards wd, the browser loads a single page at once
+	mu     sync.Mutex
+	wd     selenium.WebDriver
+	closed bool
+}
+
+func (s *seleniumBackend) start() error {
+	s.once.Do(func() {
+		serviceOpts := []selenium.ServiceOption{
+			selenium.ChromeDriver(s.config.ChromeDriverPath), // Specify the path to GeckoDriver in order to use Firefox.
+			//selenium.Output(os.Stderr),              // Output debug information to STDERR.
+		}
+		s.service, s.err = selenium.NewSeleniumService(s.config.SeleniumPath, s.config.Port, serviceOpts...)
+		if s.err != nil {
+			s.err = fmt.Errorf("cannot start selenium: %v", s.err)
+			return
+		}
+
+		// Connect to the WebDriver instance running locally.
+		//caps := selenium.Capabilities{"browserName": "firefox"}
+		caps := selenium.Capabilities{"browserName": "chrome"}
+		caps.AddChrome(chrome.Capabilities{
+			Path: s.config.ChromePath,
+		})
+		if s.config.Proxy != "" {
+			caps.AddProxy(selenium.Proxy{
+				Type: selenium.Manual,
+				HTTP: s.config.Proxy,
+				SSL:  s.config.Proxy,
+			})
+		}
+		s.wd, s.err = selenium.NewRemote(caps, fmt.Sprintf("http://localhost:%d/wd/hub", s.config.Port))
+		if s.err != nil {
+			_ = s.service.Stop()
+			s.err = fmt.Errorf("cannot connect to selenium: %v", s.err)
+			return
+		}
+		if s.config.PagesDir != "" {
+			_ = os.MkdirAll(s.config.PagesDir, 0755)
+		}
+	})
+	return s.err
+}
+
+// page loads url in the browser. A webdriver call cannot be
+// interrupted, so ctx is only checked between the calls.
+func (s *seleniumBackend) page(ctx context.Context, url string) (string, error) {
+	// the page source must be that of url, not of the page of another worker
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return "", fmt.Errorf("selenium is closed")
+	}
+	if err := s.start(); err != nil {
+		return "", err
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if err := s.wd.Get(url); err != nil {
+		return "", err
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	pageSource, err := s.wd.PageSource()
+	if err == nil && s.config.PagesDir != "" {
+		_ = ioutil.WriteFile(filepath.Join(s.config.PagesDir, path.Base(url)+".html"), []byte(pageSource), 0644)
+	}
+	return pageSource, err
+}
+
+// close quits the browser and stops the selenium server, if started.
+func (s *seleniumBackend) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if s.wd == nil {
+		return nil
+	}
+	err := s.wd.Quit()
+	if stopErr := s.service.Stop(); err == nil {
+		err = stopErr
+	}
+	return err
+}
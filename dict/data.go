@@ -0,0 +1,88 @@
+package dict
+
+// The optional interfaces of Word, for the data which is not given by
+// every dictionary. Use the functions of the same name, which handle
+// the words without it.
+
+// Accents of the audio.
+const (
+	AccentUs = "us"
+	AccentUk = "uk"
+)
+
+// Voices of the audio.
+const (
+	VoiceMale   = "male"
+	VoiceFemale = "female"
+)
+
+// Audio is a pronunciation clip of a word.
+type Audio struct {
+	Url string
+	// Accent is AccentUs or AccentUk, empty if unknown
+	Accent string
+	// Voice is VoiceMale or VoiceFemale, empty if unknown
+	Voice string
+}
+
+// AudioWord is a Word which tells the accent and voice of its audio.
+type AudioWord interface {
+	Audios() []Audio
+}
+
+// ExampleWord is a Word with english example sentences.
+type ExampleWord interface {
+	ExampleSentences() []string
+}
+
+// BasicDefinitionWord is a Word with a short definition, e.g. the
+// chinese basic definition of dict.cn.
+type BasicDefinitionWord interface {
+	// BasicDefinition returns a line for each part of speech
+	BasicDefinition() []string
+}
+
+// PictureWord is a Word with pictures, e.g. the image thumbnails of bing.
+type PictureWord interface {
+	// PictureUrls returns the urls of the pictures, the best first
+	PictureUrls() []string
+}
+
+// Audios returns the audio of w, those of Mp3 without accent unless w
+// is an AudioWord.
+func Audios(w Word) []Audio {
+	if aw, ok := w.(AudioWord); ok {
+		return aw.Audios()
+	}
+	var audios []Audio
+	for _, url := range w.Mp3() {
+		if url != "" {
+			audios = append(audios, Audio{Url: url})
+		}
+	}
+	return audios
+}
+
+// ExampleSentences returns the example sentences of w, if any.
+func ExampleSentences(w Word) []string {
+	if ew, ok := w.(ExampleWord); ok {
+		return ew.ExampleSentences()
+	}
+	return nil
+}
+
+// BasicDefinition returns the short definition of w, if any.
+func BasicDefinition(w Word) []string {
+	if bw, ok := w.(BasicDefinitionWord); ok {
+		return bw.BasicDefinition()
+	}
+	return nil
+}
+
+// Pictures returns the urls of the pictures of w, if any.
+func Pictures(w Word) []string {
+	if pw, ok := w.(PictureWord); ok {
+		return pw.PictureUrls()
+	}
+	return nil
+}
@@ -1,9 +1,82 @@
 package dict
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+)
 
 var ErrNotFound = fmt.Errorf("not found")
 
+// Kinds of lookup failures, see Error.
+var (
+	ErrRateLimited = fmt.Errorf("rate limited")
+	ErrTransient   = fmt.Errorf("transient network error")
+	ErrParse       = fmt.Errorf("parse failure")
+	ErrBlocked     = fmt.Errorf("blocked")
+)
+
+// Error is a failure of a known kind, test it with errors.Is(err, ErrTransient).
+type Error struct {
+	Kind error
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%v: %v", e.Kind, e.Err)
+}
+
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// NotFound returns an error matching ErrNotFound which tells why the
+// word is considered missing.
+func NotFound(reason string) error {
+	return &Error{Kind: ErrNotFound, Err: errors.New(reason)}
+}
+
+// IsRetryable reports whether a later attempt may succeed.
+func IsRetryable(err error) bool {
+	return errors.Is(err, ErrRateLimited) || errors.Is(err, ErrTransient)
+}
+
+// ClassifyError wraps err of a request answered with statusCode into
+// an Error. statusCode is 0 if no response was received.
+func ClassifyError(statusCode int, err error) error {
+	if err == nil {
+		return nil
+	}
+	var classified *Error
+	switch {
+	case errors.As(err, &classified):
+		return err
+	case errors.Is(err, context.Canceled):
+		return err
+	case statusCode == http.StatusTooManyRequests:
+		return &Error{Kind: ErrRateLimited, Err: err}
+	case statusCode == http.StatusForbidden:
+		return &Error{Kind: ErrBlocked, Err: err}
+	case statusCode >= 500:
+		return &Error{Kind: ErrTransient, Err: err}
+	case statusCode != 0:
+		return err
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return &Error{Kind: ErrTransient, Err: err}
+	}
+	return err
+}
+
 type Dictionary string
 
 const (
@@ -13,23 +86,18 @@ const (
 	Dictcn   Dictionary = "dictcn"
 )
 
+// Name is the display name of the registered provider.
 func (d Dictionary) Name() string {
-	switch d {
-	case Webster:
-		return "Merriam-Webster"
-	case Dictcn:
-		return "海词"
-	case BingDict:
-		return "必应词典"
-	case Collins:
-		return "Collins"
-	default:
-		return string(d)
+	if provider, ok := GetProvider(d); ok && provider.DisplayName != "" {
+		return provider.DisplayName
 	}
+	return string(d)
 }
 
 type Dict interface {
 	Lookup(word string) (Word, error)
+	// LookupContext is Lookup which gives up once ctx is done.
+	LookupContext(ctx context.Context, word string) (Word, error)
 	Parse(wordJson []byte) (Word, error)
 	Type() Dictionary
 }
@@ -42,3 +110,87 @@ type Word interface {
 	Type() Dictionary
 	Mp3() []string
 }
+
+// DefaultUserAgent is sent by the scrapers unless WithUserAgent is given.
+const DefaultUserAgent = "Mozilla/5.0 (Windows NT 6.1) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/41.0.2228.0 Safari/537.36"
+
+// Options holds the settings shared by all dictionary implementations.
+// Zero values mean "use the dictionary's default".
+type Options struct {
+	// BaseUrl replaces the scheme and host of the dictionary site,
+	// e.g. to point a dictionary at a local test server.
+	BaseUrl    string
+	HttpClient *http.Client
+	UserAgent  string
+	// Proxy is used by the default http client, nil for a direct connection
+	Proxy *url.URL
+	// Params are the settings specific to a provider, e.g. the collins backend
+	Params map[string]string
+}
+
+type Option func(o *Options)
+
+func WithBaseUrl(baseUrl string) Option {
+	return func(o *Options) {
+		o.BaseUrl = baseUrl
+	}
+}
+
+func WithHttpClient(client *http.Client) Option {
+	return func(o *Options) {
+		o.HttpClient = client
+	}
+}
+
+func WithUserAgent(userAgent string) Option {
+	return func(o *Options) {
+		o.UserAgent = userAgent
+	}
+}
+
+func WithProxy(proxy *url.URL) Option {
+	return func(o *Options) {
+		o.Proxy = proxy
+	}
+}
+
+func WithParam(key string, value string) Option {
+	return func(o *Options) {
+		if o.Params == nil {
+			o.Params = map[string]string{}
+		}
+		o.Params[key] = value
+	}
+}
+
+// NewOptions applies opts on top of the given defaults.
+func NewOptions(defaults Options, opts ...Option) Options {
+	for _, opt := range opts {
+		opt(&defaults)
+	}
+	if defaults.UserAgent == "" {
+		defaults.UserAgent = DefaultUserAgent
+	}
+	return defaults
+}
+
+// ContextClient returns a copy of client whose requests are bound to ctx,
+// for scrapers which cannot pass a context to each request themselves.
+func ContextClient(ctx context.Context, client *http.Client) *http.Client {
+	c := *client
+	transport := c.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	c.Transport = contextTransport{ctx: ctx, base: transport}
+	return &c
+}
+
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (t contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req.WithContext(t.ctx))
+}
@@ -0,0 +1,138 @@
+package dict
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClassifyError(t *testing.T) {
+	netErr := &net.OpError{Op: "dial", Net: "tcp", Err: fmt.Errorf("connection refused")}
+	tests := []struct {
+		statusCode int
+		err        error
+		kind       error
+		retryable  bool
+	}{
+		{429, fmt.Errorf("Too Many Requests"), ErrRateLimited, true},
+		{403, fmt.Errorf("Forbidden"), ErrBlocked, false},
+		{503, fmt.Errorf("Service Unavailable"), ErrTransient, true},
+		{0, netErr, ErrTransient, true},
+		{0, context.DeadlineExceeded, ErrTransient, true},
+		{0, fmt.Errorf("wrapped: %w", context.Canceled), context.Canceled, false},
+		{400, fmt.Errorf("Bad Request"), nil, false},
+		{0, NotFound("no entry"), ErrNotFound, false},
+	}
+	for _, test := range tests {
+		err := ClassifyError(test.statusCode, test.err)
+		if test.kind != nil && !errors.Is(err, test.kind) {
+			t.Errorf("%v %v: want %v, got %v", test.statusCode, test.err, test.kind, err)
+		}
+		if !errors.Is(err, test.err) {
+			t.Errorf("%v %v: original error lost: %v", test.statusCode, test.err, err)
+		}
+		if IsRetryable(err) != test.retryable {
+			t.Errorf("%v %v: want retryable %v", test.statusCode, test.err, test.retryable)
+		}
+	}
+}
+
+func TestSanitizeHtml(t *testing.T) {
+	tests := map[string]string{
+		`<div class="def" onclick="alert(1)" style="color:red">a  <b>bold</b>
+		 word</div>`: `<div class="def">a <b>bold</b> word</div>`,
+		`<a href="javascript:alert(1)">link</a><script>alert(1)</script>`: `link`,
+		`<span>1 &lt; 2</span><img src="x.png"><br/>end`:                  `<span>1 &lt; 2</span><br>end`,
+		`<li>unclosed <i class="x&quot;y">item`:                           `<li>unclosed <i class="x&#34;y">item</i></li>`,
+		`<!-- comment -->text`:                                            `text`,
+	}
+	for raw, want := range tests {
+		if got := SanitizeHtml(raw); got != want {
+			t.Errorf("%v:\nwant %v\ngot  %v", raw, want, got)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	var got Options
+	Register(Provider{
+		Name:        "test-dict",
+		DisplayName: "Test Dictionary",
+		Priority:    -1,
+		New: func(opts ...Option) (Dict, error) {
+			got = NewOptions(Options{}, opts...)
+			return nil, nil
+		},
+	})
+	if name := Dictionary("test-dict").Name(); name != "Test Dictionary" {
+		t.Fatalf("want display name, got: %v", name)
+	}
+	if name := Dictionary("unknown").Name(); name != "unknown" {
+		t.Fatalf("want unknown, got: %v", name)
+	}
+	if providers := Providers(); len(providers) == 0 || providers[0].Name != "test-dict" {
+		t.Fatalf("want test-dict first, got: %v", providers)
+	}
+	if _, err := New("test-dict", WithParam("key", "value")); err != nil || got.Params["key"] != "value" {
+		t.Fatalf("unexpected options: %+v, %v", got, err)
+	}
+	if _, err := New("unknown"); err == nil {
+		t.Fatalf("want error of unknown dictionary")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("want panic of duplicated name")
+		}
+	}()
+	Register(Provider{Name: "test-dict", New: func(opts ...Option) (Dict, error) { return nil, nil }})
+}
+
+func TestTemplate(t *testing.T) {
+	tmpl := NewTemplate("test-template", `
+		<div class="word">{{.}}</div>
+		{{define "name"}}<span>{{.}}</span>{{end}}
+	`, nil)
+	if got := tmpl.Render("<b>kestrel</b>"); got != `<div class="word">&lt;b&gt;kestrel&lt;/b&gt;</div>` {
+		t.Fatalf("unexpected default: %v", got)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "test-template.html"), []byte("<p>\n  {{.}}\n</p>\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadTemplates(dir); err != nil {
+		t.Fatalf("cannot load: %v", err)
+	}
+	defer LoadTemplates("")
+	if got := tmpl.Render("kestrel"); got != `<p>kestrel</p>` {
+		t.Fatalf("unexpected user template: %v", got)
+	}
+	// not defined by the user template
+	if got := tmpl.RenderBlock("name", "kestrel"); got != `<span>kestrel</span>` {
+		t.Fatalf("unexpected default block: %v", got)
+	}
+
+	// the words of two lines are kept apart
+	multiline := "<p>common\n    kestrel\n\n    {{index . 0}}\n    {{index . 1}}</p>\n"
+	if err := os.WriteFile(filepath.Join(dir, "test-template.html"), []byte(multiline), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadTemplates(dir); err != nil {
+		t.Fatalf("cannot load: %v", err)
+	}
+	if got := tmpl.Render([]string{"falco", "tinnunculus"}); got != `<p>common kestrel falco tinnunculus</p>` {
+		t.Fatalf("unexpected multi-line user template: %v", got)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "test-template.html"), []byte("{{if}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadTemplates(dir); err == nil {
+		t.Fatalf("want parse error")
+	}
+}
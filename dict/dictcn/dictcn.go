@@ -1,6 +1,8 @@
 package dictcn
 
 import (
+	"context"
+	_ "embed"
 	"encoding/json"
 	"fmt"
 	"github.com/PuerkitoBio/goquery"
@@ -10,6 +12,7 @@ import (
 	"net/url"
 	"strings"
 	"time"
+	"unicode"
 	"word-downloader/dict"
 )
 
@@ -34,47 +37,87 @@ func (w Word) Type() dict.Dictionary {
 }
 
 func (w Word) Mp3() []string {
-	return []string{
-		w.Audio.Us.FemaleMp3,
+	var urls []string
+	for _, audio := range w.Audios() {
+		urls = append(urls, audio.Url)
 	}
+	return urls
 }
 
 func (w Word) Pronunciation() string {
 	return fmt.Sprintf("/%v/", w.Audio.Us.Pronunciation)
 }
 
-func (w Word) DefinitionHtml(showHeadWord bool) string {
-	sb := strings.Builder{}
-	sb.WriteString(`<div class="word-content">`)
-	sb.WriteString(fmt.Sprintf(`<div class="dict-name">%v</div>`, w.Type().Name()))
+//go:embed templates/dictcn.html
+var templateText string
 
-	if showHeadWord {
-		sb.WriteString(`<div class="this-word">`)
-		sb.WriteString(w.W)
-		sb.WriteString(`</div>`)
+var tmpl = dict.NewTemplate(string(dict.Dictcn), templateText, nil)
+
+func (w Word) DefinitionHtml(showWord bool) string {
+	return tmpl.Render(struct {
+		Word
+		ShowWord bool
+	}{w, showWord})
+}
+
+var _ dict.Word = Word{}
+
+// Audios are the female then male voices of the us then uk accent.
+func (w Word) Audios() []dict.Audio {
+	var audios []dict.Audio
+	for _, audio := range []dict.Audio{
+		{Url: w.Audio.Us.FemaleMp3, Accent: dict.AccentUs, Voice: dict.VoiceFemale},
+		{Url: w.Audio.Us.MaleMp3, Accent: dict.AccentUs, Voice: dict.VoiceMale},
+		{Url: w.Audio.Uk.FemaleMp3, Accent: dict.AccentUk, Voice: dict.VoiceFemale},
+		{Url: w.Audio.Uk.MaleMp3, Accent: dict.AccentUk, Voice: dict.VoiceMale},
+	} {
+		if audio.Url != "" {
+			audios = append(audios, audio)
+		}
 	}
+	return audios
+}
 
-	sb.WriteString(`<div class="basic-def-list">`)
-	for _, b := range w.BasicDef {
-		sb.WriteString(b.Html())
+// ExampleSentences are the english examples of the definitions, which
+// are followed by their translation.
+func (w Word) ExampleSentences() []string {
+	var sentences []string
+	for _, d := range w.Defs {
+		for _, entry := range d.DefEntries {
+			for _, sub := range entry.SubDefinitionEntry {
+				for _, example := range sub.Examples {
+					if !hasHan(example.Text) {
+						sentences = append(sentences, example.Text)
+					}
+				}
+			}
+		}
 	}
-	sb.WriteString(`</div>`)
+	return sentences
+}
 
-	sb.WriteString(`</div>`)
-	return sb.String()
+func hasHan(s string) bool {
+	for _, r := range s {
+		if unicode.Is(unicode.Han, r) {
+			return true
+		}
+	}
+	return false
 }
 
-var _ dict.Word = Word{}
+func (w Word) BasicDefinition() []string {
+	var lines []string
+	for _, def := range w.BasicDef {
+		lines = append(lines, strings.TrimSpace(def.ParOfSpeech+" "+def.Def))
+	}
+	return lines
+}
 
 type BasicDefinition struct {
 	ParOfSpeech string
 	Def         string
 }
 
-func (b BasicDefinition) Html() string {
-	return fmt.Sprintf(`<div class="basic-def>"><span class="pos">%v</span>%v</div>`, b.ParOfSpeech, b.Def)
-}
-
 type Dict struct {
 	DictName   string
 	DefEntries []DefinitionEntry
@@ -85,44 +128,11 @@ type DefinitionEntry struct {
 	SubDefinitionEntry []SubDefinition
 }
 
-func (d DefinitionEntry) Html() string {
-	sb := strings.Builder{}
-	sb.WriteString(`<div class="sub-def-list">`)
-	sb.WriteString(`<table class="table-align">`)
-
-	for _, subDef := range d.SubDefinitionEntry {
-		sb.WriteString(`<tr class="table-align">`)
-		sb.WriteString(`<td class="table-align">`)
-		sb.WriteString(subDef.Html())
-		sb.WriteString(`</td>`)
-		sb.WriteString(`</tr>`)
-	}
-	sb.WriteString(`</table>`)
-	sb.WriteString(`</div>`)
-	return sb.String()
-}
-
 type SubDefinition struct {
 	Def      string
 	Examples []Example
 }
 
-func (s SubDefinition) Html() string {
-	sb := strings.Builder{}
-	sb.WriteString(fmt.Sprintf(`<div class="sub-def-content">`))
-	sb.WriteString(`<div class="sub-def">`)
-	sb.WriteString(s.Def)
-	sb.WriteString(`</div>`)
-
-	sb.WriteString(`<div class="use-examples">`)
-	for _, exp := range s.Examples {
-		sb.WriteString(exp.Html())
-	}
-	sb.WriteString("</div>")
-	sb.WriteString("</div>")
-	return sb.String()
-}
-
 type Audio struct {
 	Us struct {
 		Pronunciation string
@@ -140,12 +150,24 @@ type Example struct {
 	Text string
 }
 
-func (e Example) Html() string {
-	return fmt.Sprintf(`<div class=use-example>// %v</div>`, e.Text)
+const defaultBaseUrl = "http://dict.cn"
+
+func init() {
+	dict.Register(dict.Provider{
+		Name:         dict.Dictcn,
+		DisplayName:  "海词",
+		Priority:     30,
+		Capabilities: dict.Capabilities{Audio: true, Bilingual: true},
+		New: func(opts ...dict.Option) (dict.Dict, error) {
+			return NewDict(opts...), nil
+		},
+	})
 }
 
 type dictcnDict struct {
+	baseUrl    string
 	httpClient *http.Client
+	userAgent  string
 }
 
 func (dictcn *dictcnDict) Parse(wordJson []byte) (dict.Word, error) {
@@ -154,17 +176,23 @@ func (dictcn *dictcnDict) Parse(wordJson []byte) (dict.Word, error) {
 	return word, err
 }
 
-func NewDict() *dictcnDict {
-	return &dictcnDict{
-		httpClient: &http.Client{
+func NewDict(opts ...dict.Option) *dictcnDict {
+	options := dict.NewOptions(dict.Options{BaseUrl: defaultBaseUrl}, opts...)
+	if options.HttpClient == nil {
+		options.HttpClient = &http.Client{
 			Transport: &http.Transport{
-				Proxy:               nil,
+				Proxy:               http.ProxyURL(options.Proxy),
 				DialContext:         (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
 				MaxIdleConns:        256,
 				MaxIdleConnsPerHost: 256,
 				IdleConnTimeout:     time.Minute * 10,
 			},
-		},
+		}
+	}
+	return &dictcnDict{
+		baseUrl:    strings.TrimSuffix(options.BaseUrl, "/"),
+		httpClient: options.HttpClient,
+		userAgent:  options.UserAgent,
 	}
 }
 
@@ -173,10 +201,14 @@ func (dictcn *dictcnDict) Type() dict.Dictionary {
 }
 
 func (dictcn *dictcnDict) Lookup(word string) (dict.Word, error) {
+	return dictcn.LookupContext(context.Background(), word)
+}
+
+func (dictcn *dictcnDict) LookupContext(ctx context.Context, word string) (dict.Word, error) {
 	col := colly.NewCollector(
-		colly.UserAgent("Mozilla/5.0 (Windows NT 6.1) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/41.0.2228.0 Safari/537.36"),
+		colly.UserAgent(dictcn.userAgent),
 	)
-	col.SetClient(dictcn.httpClient)
+	col.SetClient(dict.ContextClient(ctx, dictcn.httpClient))
 
 	out := Word{}
 
@@ -273,16 +305,25 @@ func (dictcn *dictcnDict) Lookup(word string) (dict.Word, error) {
 
 	urlEncodedWord := url.QueryEscape(word)
 	searchUrl := fmt.Sprintf(
-		"http://dict.cn/%v",
+		"%v/%v",
+		dictcn.baseUrl,
 		urlEncodedWord,
 	)
+	var statusCode int
+	col.OnError(func(response *colly.Response, err error) {
+		statusCode = response.StatusCode
+	})
+
 	err := col.Visit(searchUrl)
 	if err != nil {
-		return Word{}, err
+		return Word{}, dict.ClassifyError(statusCode, err)
 	}
 
 	if out.W == "" {
-		return Word{}, dict.ErrNotFound
+		return Word{}, dict.NotFound("no .keyword on the page")
+	}
+	if len(out.BasicDef) == 0 && len(out.Defs) == 0 {
+		return Word{}, &dict.Error{Kind: dict.ErrParse, Err: fmt.Errorf("no definition of '%v'", out.W)}
 	}
 
 	return out, nil
@@ -2,10 +2,75 @@ package dictcn
 
 import (
 	"encoding/json"
+	"errors"
+	"net/http"
+	"path"
+	"reflect"
+	"strings"
 	"testing"
+	"word-downloader/dict"
+	"word-downloader/dict/dicttest"
 )
 
+func newTestDict(t *testing.T) *dictcnDict {
+	srv := dicttest.NewServer(t, func(r *http.Request) string {
+		return path.Base(r.URL.Path)
+	})
+	return NewDict(dict.WithBaseUrl(srv.URL), dict.WithHttpClient(srv.Client()))
+}
+
+func TestDictcnDict_Lookup(t *testing.T) {
+	dictcn := newTestDict(t)
+	word, err := dictcn.Lookup("regret")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	dicttest.Golden(t, "regret", word)
+}
+
+func TestWord_DefinitionHtml(t *testing.T) {
+	word, err := newTestDict(t).Lookup("regret")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	dicttest.GoldenText(t, "regret.golden.html", word.DefinitionHtml(true)+"\n")
+
+	// scraped text is escaped
+	html := Word{W: "x", BasicDef: []BasicDefinition{{ParOfSpeech: "n.", Def: `<script>alert(1)</script>`}}}.DefinitionHtml(false)
+	if !strings.Contains(html, `<div class="basic-def"><span class="pos">n.</span>&lt;script&gt;alert(1)&lt;/script&gt;</div>`) {
+		t.Fatalf("unexpected html: %v", html)
+	}
+}
+
+func TestWord_Data(t *testing.T) {
+	word, err := newTestDict(t).Lookup("regret")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	audios := dict.Audios(word)
+	if len(audios) != 4 || audios[0].Accent != dict.AccentUs || audios[0].Voice != dict.VoiceFemale ||
+		audios[3].Accent != dict.AccentUk || audios[3].Voice != dict.VoiceMale {
+		t.Errorf("unexpected audios: %v", audios)
+	}
+	if basic := dict.BasicDefinition(word); !reflect.DeepEqual(basic, []string{"v. 后悔；懊悔；遗憾；抱歉", "n. 遗憾；懊悔；歉意"}) {
+		t.Errorf("unexpected basic definition: %q", basic)
+	}
+	// the translations are left out
+	if examples := dict.ExampleSentences(word); len(examples) == 0 || examples[0] != "I regret saying that." || examples[1] != "We regret to inform you that your application has been rejected." {
+		t.Errorf("unexpected examples: %q", examples)
+	}
+}
+
+func TestDictcnDict_LookupNotFound(t *testing.T) {
+	dictcn := newTestDict(t)
+	_, err := dictcn.Lookup("asdfghjk")
+	if !errors.Is(err, dict.ErrNotFound) {
+		t.Fatalf("want ErrNotFound, got: %v", err)
+	}
+}
+
 func TestCollinsDict_Lookup(t *testing.T) {
+	dicttest.SkipUnlessLive(t)
 	dict := NewDict()
 	word, err := dict.Lookup("regret")
 	if err != nil {
@@ -14,3 +79,11 @@ func TestCollinsDict_Lookup(t *testing.T) {
 	buf, _ := json.MarshalIndent(word, "", " ")
 	t.Logf("%v", string(buf))
 }
+
+// TestRecordPages saves the fixtures from dict.cn, with -record.
+func TestRecordPages(t *testing.T) {
+	dicttest.RecordPages(t, defaultBaseUrl, map[string]string{
+		"regret":   "/regret",
+		"asdfghjk": "/asdfghjk",
+	})
+}
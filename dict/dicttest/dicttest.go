@@ -0,0 +1,168 @@
+// Package dicttest serves recorded dictionary pages to the scrapers, so
+// their tests run without network access.
+//
+// Fixtures live in the testdata directory of the package under test:
+//
+//	<word>.html          served with 200
+//	<word>.<status>.html served with the given status, e.g. regret.429.html
+//	<word>.golden.json   expected lookup result, see Golden
+//	<word>.golden.html   expected card html, see GoldenText
+//
+// The pages are saved from the real sites by RecordPages, with -record,
+// then the golden files are rewritten with -update.
+package dicttest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"word-downloader/dict"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with the current lookup results")
+var live = flag.Bool("live", false, "run tests that query the real dictionary sites")
+var record = flag.Bool("record", false, "save the fixture pages from the real dictionary sites, see RecordPages")
+
+// NewServer starts a server answering each request with the fixture of the
+// word returned by wordOf. The server is closed when the test finishes.
+func NewServer(t *testing.T, wordOf func(r *http.Request) string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		word := wordOf(r)
+		page, err := ioutil.ReadFile(filepath.Join("testdata", word+".html"))
+		if err == nil {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write(page)
+			return
+		}
+		matches, _ := filepath.Glob(filepath.Join("testdata", word+".[0-9][0-9][0-9].html"))
+		if len(matches) > 0 {
+			// <word>.<status>.html
+			status, _ := strconv.Atoi(strings.TrimPrefix(filepath.Ext(strings.TrimSuffix(matches[0], ".html")), "."))
+			page, err = ioutil.ReadFile(matches[0])
+			if err == nil {
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				w.WriteHeader(status)
+				_, _ = w.Write(page)
+				return
+			}
+		}
+		t.Errorf("no fixture for %v", r.URL)
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// Golden compares got, encoded as indented json, with testdata/<name>.golden.json.
+// Run the tests with -update to rewrite the golden file instead.
+func Golden(t *testing.T, name string, got interface{}) {
+	t.Helper()
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", " ")
+	if err := encoder.Encode(got); err != nil {
+		t.Fatalf("cannot marshal %v: %v", name, err)
+	}
+	compareGolden(t, filepath.Join("testdata", name+".golden.json"), out.Bytes())
+}
+
+// GoldenText compares got with the file testdata/<name>.
+// Run the tests with -update to rewrite the golden file instead.
+func GoldenText(t *testing.T, name string, got string) {
+	t.Helper()
+	compareGolden(t, filepath.Join("testdata", name), []byte(got))
+}
+
+func compareGolden(t *testing.T, goldenFile string, buf []byte) {
+	t.Helper()
+	if *update {
+		if err := ioutil.WriteFile(goldenFile, buf, 0644); err != nil {
+			t.Fatalf("cannot update golden file: %v", err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(goldenFile)
+	if os.IsNotExist(err) {
+		t.Fatalf("missing golden file %v, run with -update to create it", goldenFile)
+	} else if err != nil {
+		t.Fatalf("cannot read golden file: %v", err)
+	}
+	if !bytes.Equal(want, buf) {
+		t.Errorf("%v mismatch\nwant: %s\ngot:  %s", goldenFile, want, buf)
+	}
+}
+
+// SkipUnlessLive skips tests that need the real dictionary sites,
+// unless the tests are run with -live.
+func SkipUnlessLive(t *testing.T) {
+	t.Helper()
+	if !*live {
+		t.Skip("queries the live site, run with -live")
+	}
+}
+
+// RecordPages saves the page of the real site of each word of pages, by
+// its request uri, as the fixture of the word, replacing the old one. A
+// page is saved as <word>.<status>.html unless its status is 200. It runs
+// only with -record, the pages are then checked by the golden files.
+//
+// The scripts and styles of the pages are dropped, which the scrapers do
+// not read, to keep the fixtures small.
+func RecordPages(t *testing.T, site string, pages map[string]string) {
+	t.Helper()
+	if !*record {
+		t.Skip("records the pages of the live site, run with -record")
+	}
+	client := &http.Client{Timeout: time.Minute}
+	for word, uri := range pages {
+		req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(site, "/")+uri, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("User-Agent", dict.DefaultUserAgent)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("cannot record %v: %v", word, err)
+		}
+		page, err := ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			t.Fatalf("cannot record %v: %v", word, err)
+		}
+		old, _ := filepath.Glob(filepath.Join("testdata", word+".[0-9][0-9][0-9].html"))
+		for _, file := range append(old, filepath.Join("testdata", word+".html")) {
+			_ = os.Remove(file)
+		}
+		file := filepath.Join("testdata", word+".html")
+		if resp.StatusCode != http.StatusOK {
+			file = filepath.Join("testdata", fmt.Sprintf("%v.%v.html", word, resp.StatusCode))
+		}
+		if err := ioutil.WriteFile(file, trimPage(page), 0644); err != nil {
+			t.Fatalf("cannot record %v: %v", word, err)
+		}
+		t.Logf("record %v: %v", file, resp.Status)
+	}
+}
+
+var (
+	scriptRe = regexp.MustCompile(`(?is)<script\b[^>]*>.*?</script>`)
+	styleRe  = regexp.MustCompile(`(?is)<style\b[^>]*>.*?</style>`)
+)
+
+// trimPage drops the scripts and styles of page.
+func trimPage(page []byte) []byte {
+	page = scriptRe.ReplaceAll(page, nil)
+	return styleRe.ReplaceAll(page, nil)
+}
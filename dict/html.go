@@ -0,0 +1,80 @@
+package dict
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// tags kept by SanitizeHtml, with only their class attribute
+var allowedTags = map[atom.Atom]bool{
+	atom.Div: true, atom.Span: true, atom.P: true, atom.Br: true,
+	atom.Ul: true, atom.Ol: true, atom.Li: true,
+	atom.Table: true, atom.Thead: true, atom.Tbody: true, atom.Tr: true, atom.Td: true, atom.Th: true,
+	atom.Strong: true, atom.B: true, atom.Em: true, atom.I: true, atom.Sup: true, atom.Sub: true,
+}
+
+// tags removed by SanitizeHtml together with their content, other tags
+// (e.g. links) are replaced by their content
+var droppedTags = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Img: true, atom.Svg: true, atom.Audio: true, atom.Video: true,
+	atom.Iframe: true, atom.Object: true, atom.Embed: true,
+	atom.Form: true, atom.Input: true, atom.Button: true, atom.Select: true, atom.Textarea: true,
+}
+
+var spaces = regexp.MustCompile(`\s+`)
+
+// SanitizeHtml makes html scraped from a dictionary site safe to put on a card:
+// scripts, media and forms are removed, links are unwrapped, and all
+// attributes but class are dropped.
+func SanitizeHtml(raw string) string {
+	context := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	nodes, err := html.ParseFragment(strings.NewReader(raw), context)
+	if err != nil {
+		return html.EscapeString(raw)
+	}
+	sb := strings.Builder{}
+	for _, n := range nodes {
+		sanitizeNode(&sb, n)
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+func sanitizeNode(sb *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		sb.WriteString(html.EscapeString(spaces.ReplaceAllString(n.Data, " ")))
+		return
+	case html.ElementNode:
+		if droppedTags[n.DataAtom] {
+			return
+		}
+		if allowedTags[n.DataAtom] {
+			sb.WriteString("<" + n.Data)
+			for _, attr := range n.Attr {
+				if attr.Namespace == "" && attr.Key == "class" {
+					sb.WriteString(` class="` + html.EscapeString(attr.Val) + `"`)
+				}
+			}
+			sb.WriteString(">")
+			if n.DataAtom == atom.Br {
+				return
+			}
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				sanitizeNode(sb, c)
+			}
+			sb.WriteString("</" + n.Data + ">")
+			return
+		}
+	case html.DocumentNode:
+	default:
+		// comments and doctypes
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sanitizeNode(sb, c)
+	}
+}
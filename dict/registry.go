@@ -0,0 +1,89 @@
+package dict
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Capabilities tell what the words of a dictionary provide.
+type Capabilities struct {
+	Audio    bool
+	Pictures bool
+	// Bilingual definitions, e.g. english and chinese
+	Bilingual bool
+}
+
+// Provider describes a dictionary to the registry. A provider package
+// registers itself in init, so importing it is enough to enable it.
+type Provider struct {
+	Name        Dictionary
+	DisplayName string
+	// Priority orders the dictionaries, e.g. on an anki card, lowest first
+	Priority     int
+	Capabilities Capabilities
+	// New creates the dictionary, Options.Params holds the settings
+	// specific to the provider.
+	New func(opts ...Option) (Dict, error)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[Dictionary]Provider{}
+)
+
+// Register makes a provider available by its name. It panics if the
+// name is registered twice or the provider has no New.
+func Register(provider Provider) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if provider.New == nil {
+		panic(fmt.Sprintf("dict: Register %v without New", provider.Name))
+	}
+	if _, dup := registry[provider.Name]; dup {
+		panic(fmt.Sprintf("dict: Register called twice for %v", provider.Name))
+	}
+	registry[provider.Name] = provider
+}
+
+// GetProvider returns the registered provider of name.
+func GetProvider(name Dictionary) (Provider, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	provider, ok := registry[name]
+	return provider, ok
+}
+
+// Providers returns the registered providers, ordered by priority and name.
+func Providers() []Provider {
+	registryMu.RLock()
+	var providers []Provider
+	for _, provider := range registry {
+		providers = append(providers, provider)
+	}
+	registryMu.RUnlock()
+	sort.Slice(providers, func(i, j int) bool {
+		if providers[i].Priority != providers[j].Priority {
+			return providers[i].Priority < providers[j].Priority
+		}
+		return providers[i].Name < providers[j].Name
+	})
+	return providers
+}
+
+// New creates the dictionary of the registered provider name.
+func New(name Dictionary, opts ...Option) (Dict, error) {
+	provider, ok := GetProvider(name)
+	if !ok {
+		return nil, fmt.Errorf("unsupported dictionary: %v", name)
+	}
+	return provider.New(opts...)
+}
+
+// Priority of name, unregistered dictionaries come last.
+func (d Dictionary) Priority() int {
+	if provider, ok := GetProvider(d); ok {
+		return provider.Priority
+	}
+	return int(^uint(0) >> 1)
+}
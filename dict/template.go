@@ -0,0 +1,146 @@
+package dict
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TemplateFuncs are available to all the templates.
+var TemplateFuncs = template.FuncMap{
+	// sanitize keeps the whitelisted tags of scraped html, see SanitizeHtml
+	"sanitize": func(raw string) template.HTML {
+		return template.HTML(SanitizeHtml(raw))
+	},
+	"inc": func(i int) int {
+		return i + 1
+	},
+	"trim": strings.TrimSpace,
+}
+
+// Template renders html with a built-in default, which the file
+// <name>.html of the template directory replaces, see LoadTemplates.
+//
+// The lines of a template are trimmed and joined before it is parsed, so
+// it can be indented freely and renders to a single line, see
+// compactTemplate. The default and the user templates are compacted alike,
+// so a default template written by the templates command renders the same.
+type Template struct {
+	name    string
+	text    string
+	funcs   template.FuncMap
+	builtin *template.Template
+	// user is the template loaded from the template directory, guarded by templatesMu
+	user *template.Template
+}
+
+var (
+	templatesMu sync.RWMutex
+	templates   = map[string]*Template{}
+)
+
+// NewTemplate registers the default template name. funcs are added to
+// TemplateFuncs. It panics if text cannot be parsed or the name is
+// registered twice.
+func NewTemplate(name string, text string, funcs template.FuncMap) *Template {
+	t := &Template{name: name, text: text, funcs: funcs}
+	t.builtin = template.Must(t.parse(compactTemplate(text)))
+
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	if _, dup := templates[name]; dup {
+		panic(fmt.Sprintf("dict: NewTemplate called twice for %v", name))
+	}
+	templates[name] = t
+	return t
+}
+
+func (t *Template) parse(text string) (*template.Template, error) {
+	return template.New(t.name).Funcs(TemplateFuncs).Funcs(t.funcs).Parse(text)
+}
+
+// compactTemplate trims the lines of text and joins those which are not
+// blank. Two lines are joined by a space, which keeps apart their words,
+// e.g. of text or actions, unless one of them is an html tag at the join,
+// whose indentation is dropped.
+func compactTemplate(text string) string {
+	sb := strings.Builder{}
+	prev := ""
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		if prev != "" && !strings.HasSuffix(prev, ">") && !strings.HasPrefix(line, "<") {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(line)
+		prev = line
+	}
+	return sb.String()
+}
+
+// Render executes the template with data.
+func (t *Template) Render(data interface{}) string {
+	return t.RenderBlock(t.name, data)
+}
+
+// RenderBlock executes the block name defined in the template, the block
+// of the default template is used if the user template has no such block.
+func (t *Template) RenderBlock(name string, data interface{}) string {
+	templatesMu.RLock()
+	tmpl := t.user
+	templatesMu.RUnlock()
+	if tmpl == nil || tmpl.Lookup(name) == nil {
+		tmpl = t.builtin
+	}
+	sb := strings.Builder{}
+	if err := tmpl.ExecuteTemplate(&sb, name, data); err != nil {
+		log.Printf("error: cannot render template %v: %v", name, err)
+	}
+	return sb.String()
+}
+
+// LoadTemplates replaces the default templates by the files <name>.html
+// of dir, those without a file in dir are reset to the default.
+func LoadTemplates(dir string) error {
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	for name, t := range templates {
+		t.user = nil
+		if dir == "" {
+			continue
+		}
+		path := filepath.Join(dir, name+".html")
+		buf, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		t.user, err = t.parse(compactTemplate(string(buf)))
+		if err != nil {
+			return fmt.Errorf("cannot parse %v: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// DefaultTemplates returns the names of the registered templates, sorted,
+// and their default text.
+func DefaultTemplates() ([]string, map[string]string) {
+	templatesMu.RLock()
+	defer templatesMu.RUnlock()
+	var names []string
+	texts := map[string]string{}
+	for name, t := range templates {
+		names = append(names, name)
+		texts[name] = t.text
+	}
+	sort.Strings(names)
+	return names, texts
+}
@@ -1,10 +1,13 @@
 package webster
 
 import (
+	"context"
+	_ "embed"
 	"encoding/json"
 	"fmt"
 	"github.com/PuerkitoBio/goquery"
 	"github.com/gocolly/colly/v2"
+	"html/template"
 	"net"
 	"net/http"
 	"net/url"
@@ -42,115 +45,80 @@ func (w Word) Pronunciation() string {
 	return w.Audio.Syllables + " | " + fmt.Sprintf("/%v/", w.Audio.Pronunciation)
 }
 
+//go:embed templates/webster.html
+var templateText string
+
+var tmpl = dict.NewTemplate(string(dict.Webster), templateText, template.FuncMap{
+	"pos": partOfSpeech,
+})
+
 func (w Word) DefinitionHtml(showWord bool) string {
-	sb := strings.Builder{}
-	sb.WriteString(`<div class="word-content">`)
+	return tmpl.Render(struct {
+		Word
+		ShowWord bool
+	}{w, showWord})
+}
 
-	sb.WriteString(fmt.Sprintf(`<div class="dict-name">%v</div>`, w.Type().Name()))
+var _ dict.Word = Word{}
 
-	if showWord {
-		sb.WriteString(`<div class="this-word">`)
-		sb.WriteString(w.W)
-		sb.WriteString(`</div>`)
+func (w Word) Audios() []dict.Audio {
+	if w.Audio.Mp3 == "" {
+		return nil
 	}
+	return []dict.Audio{{Url: w.Audio.Mp3, Accent: dict.AccentUs}}
+}
 
+func (w Word) ExampleSentences() []string {
+	var sentences []string
 	for _, def := range w.Defs {
-		sb.WriteString(def.Html())
+		for _, entry := range def.DefinitionEntry {
+			for _, sub := range entry.SubDefinitionEntry {
+				for _, example := range sub.Examples {
+					if text := strings.TrimSpace(example.Text); text != "" {
+						sentences = append(sentences, text)
+					}
+				}
+			}
+		}
 	}
-
-	sb.WriteString(`</div>`)
-	return sb.String()
+	return sentences
 }
 
-var _ dict.Word = Word{}
+// BasicDefinition is the first definition of each part of speech.
+func (w Word) BasicDefinition() []string {
+	var lines []string
+	for _, def := range w.Defs {
+		for _, entry := range def.DefinitionEntry {
+			if len(entry.SubDefinitionEntry) == 0 {
+				continue
+			}
+			// the definitions are "a : to mourn the loss of"
+			d := entry.SubDefinitionEntry[0].Def
+			if i := strings.Index(d, ": "); i >= 0 {
+				d = d[i+2:]
+			}
+			lines = append(lines, strings.TrimSpace(def.PartOfSpeech+" "+strings.TrimSpace(d)))
+			break
+		}
+	}
+	return lines
+}
 
 type Definition struct {
 	PartOfSpeech    string
 	DefinitionEntry []DefinitionEntry
 }
 
-func (d Definition) Html() string {
-	sb := strings.Builder{}
-	sb.WriteString(`<div class="definitions">`)
-
-	sb.WriteString(`<div class="pos">`)
-	sb.WriteString(partOfSpeech(d.PartOfSpeech))
-	sb.WriteString(`</div>`)
-
-	for i, subDef := range d.DefinitionEntry {
-		sb.WriteString(`<div class="def-entry">`)
-
-		sb.WriteString(`<table class="table-align">`)
-		sb.WriteString(`<tr class="table-align">`)
-
-		sb.WriteString(`<td class="table-align">`)
-		sb.WriteString(`<div class="serial-no">`)
-		sb.WriteString(fmt.Sprintf("%v", i+1))
-		sb.WriteString(`</div>`)
-		sb.WriteString("</td>")
-
-		sb.WriteString("<td>")
-		sb.WriteString(subDef.Html())
-		sb.WriteString("</td>")
-
-		sb.WriteString("</tr>")
-		sb.WriteString("</table>")
-
-		sb.WriteString(`</div>`)
-	}
-
-	sb.WriteString("</div>")
-	return sb.String()
-}
-
 type DefinitionEntry struct {
 	PartOfSpeech       string
 	SubDefinitionEntry []SubDefinition
 }
 
-func (d DefinitionEntry) Html() string {
-	sb := strings.Builder{}
-	sb.WriteString(`<div class="sub-def-list">`)
-	sb.WriteString(`<table class="table-align">`)
-	if d.PartOfSpeech != "" {
-		sb.WriteString(`<div class="pos">`)
-		sb.WriteString(partOfSpeech(d.PartOfSpeech))
-		sb.WriteString(`</div>`)
-	}
-
-	for _, subDef := range d.SubDefinitionEntry {
-		sb.WriteString(`<tr class="table-align">`)
-		sb.WriteString(`<td class="table-align">`)
-		sb.WriteString(subDef.Html())
-		sb.WriteString(`</td>`)
-		sb.WriteString(`</tr>`)
-	}
-	sb.WriteString(`</table>`)
-	sb.WriteString(`</div>`)
-	return sb.String()
-}
-
 type SubDefinition struct {
 	Def      string
 	Examples []Example
 }
 
-func (s SubDefinition) Html() string {
-	sb := strings.Builder{}
-	sb.WriteString(fmt.Sprintf(`<div class="sub-def-content">`))
-	sb.WriteString(`<div class="sub-def">`)
-	sb.WriteString(s.Def)
-	sb.WriteString(`</div>`)
-
-	sb.WriteString(`<div class="use-examples">`)
-	for _, exp := range s.Examples {
-		sb.WriteString(exp.Html())
-	}
-	sb.WriteString("</div>")
-	sb.WriteString("</div>")
-	return sb.String()
-}
-
 type Audio struct {
 	Syllables     string
 	Pronunciation string
@@ -161,12 +129,24 @@ type Example struct {
 	Text string
 }
 
-func (e Example) Html() string {
-	return fmt.Sprintf(`<div class=use-example>// %v</div>`, e.Text)
+const defaultBaseUrl = "https://www.merriam-webster.com"
+
+func init() {
+	dict.Register(dict.Provider{
+		Name:         dict.Webster,
+		DisplayName:  "Merriam-Webster",
+		Priority:     20,
+		Capabilities: dict.Capabilities{Audio: true},
+		New: func(opts ...dict.Option) (dict.Dict, error) {
+			return NewDict(opts...), nil
+		},
+	})
 }
 
 type websterDict struct {
+	baseUrl    string
 	httpClient *http.Client
+	userAgent  string
 }
 
 func (webster *websterDict) Parse(wordJson []byte) (dict.Word, error) {
@@ -175,17 +155,23 @@ func (webster *websterDict) Parse(wordJson []byte) (dict.Word, error) {
 	return word, err
 }
 
-func NewDict() *websterDict {
-	return &websterDict{
-		httpClient: &http.Client{
+func NewDict(opts ...dict.Option) *websterDict {
+	options := dict.NewOptions(dict.Options{BaseUrl: defaultBaseUrl}, opts...)
+	if options.HttpClient == nil {
+		options.HttpClient = &http.Client{
 			Transport: &http.Transport{
-				Proxy:               nil,
+				Proxy:               http.ProxyURL(options.Proxy),
 				DialContext:         (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
 				MaxIdleConns:        256,
 				MaxIdleConnsPerHost: 256,
 				IdleConnTimeout:     time.Minute * 10,
 			},
-		},
+		}
+	}
+	return &websterDict{
+		baseUrl:    strings.TrimSuffix(options.BaseUrl, "/"),
+		httpClient: options.HttpClient,
+		userAgent:  options.UserAgent,
 	}
 }
 
@@ -194,10 +180,14 @@ func (webster *websterDict) Type() dict.Dictionary {
 }
 
 func (webster *websterDict) Lookup(word string) (dict.Word, error) {
+	return webster.LookupContext(context.Background(), word)
+}
+
+func (webster *websterDict) LookupContext(ctx context.Context, word string) (dict.Word, error) {
 	col := colly.NewCollector(
-		colly.UserAgent("Mozilla/5.0 (Windows NT 6.1) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/41.0.2228.0 Safari/537.36"),
+		colly.UserAgent(webster.userAgent),
 	)
-	col.SetClient(webster.httpClient)
+	col.SetClient(dict.ContextClient(ctx, webster.httpClient))
 
 	out := Word{}
 
@@ -275,18 +265,31 @@ func (webster *websterDict) Lookup(word string) (dict.Word, error) {
 		r.Headers.Add("accept", "*/*")
 	})
 
+	// merriam-webster answers unknown words with 404
+	var statusCode int
+	col.OnError(func(response *colly.Response, err error) {
+		statusCode = response.StatusCode
+	})
+
 	urlEncodedWord := url.QueryEscape(word)
 	searchUrl := fmt.Sprintf(
-		"https://www.merriam-webster.com/dictionary/%v",
+		"%v/dictionary/%v",
+		webster.baseUrl,
 		urlEncodedWord,
 	)
 	err := col.Visit(searchUrl)
+	if statusCode == http.StatusNotFound {
+		return Word{}, dict.NotFound("page not found (404)")
+	}
 	if err != nil {
-		return Word{}, err
+		return Word{}, dict.ClassifyError(statusCode, err)
 	}
 
 	if out.W == "" {
-		return Word{}, dict.ErrNotFound
+		return Word{}, dict.NotFound("no .hword on the page")
+	}
+	if len(out.Defs) == 0 {
+		return Word{}, &dict.Error{Kind: dict.ErrParse, Err: fmt.Errorf("no definition of '%v'", out.W)}
 	}
 
 	return out, nil
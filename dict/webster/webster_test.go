@@ -1,11 +1,64 @@
 package webster
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"net/http"
+	"path"
 	"testing"
+	"word-downloader/dict"
+	"word-downloader/dict/dicttest"
 )
 
+func newTestDict(t *testing.T) *websterDict {
+	srv := dicttest.NewServer(t, func(r *http.Request) string {
+		return path.Base(r.URL.Path)
+	})
+	return NewDict(dict.WithBaseUrl(srv.URL), dict.WithHttpClient(srv.Client()))
+}
+
+func TestWebsterDict_Lookup(t *testing.T) {
+	webster := newTestDict(t)
+	for _, word := range []string{"dexterous", "regret"} {
+		t.Run(word, func(t *testing.T) {
+			w, err := webster.Lookup(word)
+			if err != nil {
+				t.Fatalf("cannot lookup: %v", err)
+			}
+			dicttest.Golden(t, word, w)
+		})
+	}
+}
+
+func TestWebsterDict_LookupNotFound(t *testing.T) {
+	webster := newTestDict(t)
+	_, err := webster.Lookup("asdfghjk")
+	if !errors.Is(err, dict.ErrNotFound) {
+		t.Fatalf("want ErrNotFound, got: %v", err)
+	}
+}
+
+func TestWebsterDict_LookupRateLimited(t *testing.T) {
+	webster := newTestDict(t)
+	_, err := webster.Lookup("ratelimited")
+	if !errors.Is(err, dict.ErrRateLimited) {
+		t.Fatalf("want ErrRateLimited, got: %v", err)
+	}
+}
+
+func TestWebsterDict_LookupCanceled(t *testing.T) {
+	webster := newTestDict(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := webster.LookupContext(ctx, "regret")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("want context.Canceled, got: %v", err)
+	}
+}
+
 func Test_a(t *testing.T) {
+	dicttest.SkipUnlessLive(t)
 	dict := NewDict()
 	word, err := dict.Lookup("dexterous")
 	if err != nil {
@@ -15,3 +68,12 @@ func Test_a(t *testing.T) {
 	buf, _ := json.MarshalIndent(word, "", " ")
 	t.Logf("w: %v", string(buf))
 }
+
+// TestRecordPages saves the fixtures from merriam-webster.com, with -record.
+func TestRecordPages(t *testing.T) {
+	dicttest.RecordPages(t, defaultBaseUrl, map[string]string{
+		"regret":    "/dictionary/regret",
+		"dexterous": "/dictionary/dexterous",
+		"asdfghjk":  "/dictionary/asdfghjk",
+	})
+}
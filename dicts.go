@@ -0,0 +1,20 @@
+package main
+
+import (
+	"word-downloader/dict"
+
+	// the dictionaries of -dicts, a provider registers itself when imported
+	_ "word-downloader/dict/bingdict"
+	_ "word-downloader/dict/collins"
+	_ "word-downloader/dict/dictcn"
+	_ "word-downloader/dict/webster"
+)
+
+// dictNames returns the names of the registered dictionaries.
+func dictNames() []string {
+	var names []string
+	for _, provider := range dict.Providers() {
+		names = append(names, string(provider.Name))
+	}
+	return names
+}
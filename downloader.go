@@ -0,0 +1,379 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+	"word-downloader/cache"
+	"word-downloader/dict"
+	"word-downloader/media"
+
+	"golang.org/x/time/rate"
+)
+
+type Downloader struct {
+	dict    dict.Dict
+	limiter *rate.Limiter
+	// timeout of each lookup or download request
+	timeout time.Duration
+	cache   cache.Cache
+	// mu guards the writes to audio-error.txt and failures
+	mu sync.Mutex
+	// audioDir holds the mp3 stored by the earlier versions, see mediaStore
+	audioDir     string
+	audioErrFile *os.File
+	// failures taken from audio-error.txt by -retry-failed
+	failures []failure
+	//
+	ankiFile *os.File
+}
+
+func newDownloader(dict dict.Dict, limiter *rate.Limiter, timeout time.Duration) *Downloader {
+	myDictDir := dictDir(dict.Type())
+	err := os.MkdirAll(myDictDir, 0755)
+	if err != nil {
+		log.Fatalf("error: cannot mkdir: %v", err)
+		return nil
+	}
+	downloader := &Downloader{
+		dict:     dict,
+		limiter:  limiter,
+		timeout:  timeout,
+		audioDir: filepath.Join(myDictDir, "audio"),
+	}
+
+	downloader.cache, err = openCache(dict)
+	if err != nil {
+		log.Fatalf("error: cannot open cache: %v", err)
+	}
+
+	errFile, err := os.OpenFile(filepath.Join(myDictDir, "audio-error.txt"), os.O_RDWR|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		log.Fatalf("error: cannot create audio-error.txt: %v", err)
+	}
+	downloader.audioErrFile = errFile
+	if *retryFailed {
+		if err := downloader.takeFailures(); err != nil {
+			log.Fatalf("error: cannot read audio-error.txt: %v", err)
+		}
+	}
+
+	return downloader
+}
+
+// close flushes the cache and audio-error.txt to disk, and closes the
+// dictionary.
+func (d *Downloader) close() {
+	// failures which were not retried
+	for _, f := range d.failures {
+		buf, _ := json.Marshal(f)
+		if _, err := d.audioErrFile.Write(append(buf, '\n')); err != nil {
+			log.Printf("error: cannot write to audio-error.txt: %v", err)
+		}
+	}
+	if err := d.audioErrFile.Sync(); err != nil {
+		log.Printf("error: cannot sync audio-error.txt: %v", err)
+	}
+	_ = d.audioErrFile.Close()
+	if err := d.cache.Close(); err != nil {
+		log.Printf("error: cannot close cache: %v", err)
+	}
+	// e.g. the browser of collins
+	if closer, ok := d.dict.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Printf("error: cannot close %v: %v", d.dict.Type(), err)
+		}
+	}
+}
+
+// openCache opens the cache selected by -cache in the directory of dictionary.
+func openCache(dictionary dict.Dict) (cache.Cache, error) {
+	myDictDir := dictDir(dictionary.Type())
+	wordsTxt := filepath.Join(myDictDir, "words.txt")
+	switch *cacheType {
+	case "file":
+		return cache.OpenFile(wordsTxt, dictionary.Parse)
+	case "bolt":
+		wordsDb := filepath.Join(myDictDir, "words.db")
+		_, dbErr := os.Stat(wordsDb)
+		txtInfo, txtErr := os.Stat(wordsTxt)
+		if os.IsNotExist(dbErr) && txtErr == nil && txtInfo.Size() > 0 {
+			if err := createWordsDb(dictionary, wordsDb); err != nil {
+				return nil, fmt.Errorf("cannot import %v: %v", wordsTxt, err)
+			}
+		}
+		return cache.OpenBolt(wordsDb)
+	default:
+		return nil, fmt.Errorf("unknown cache type '%v'", *cacheType)
+	}
+}
+
+// createWordsDb creates the words.db of dictionary from its words.txt, the
+// first time the bolt cache is used. words.txt is left untouched.
+func createWordsDb(dictionary dict.Dict, wordsDb string) error {
+	// words.db is only created once all the words are imported
+	tmpFile := wordsDb + ".tmp"
+	_ = os.Remove(tmpFile)
+	dst, err := cache.OpenBolt(tmpFile)
+	if err != nil {
+		return err
+	}
+	count, err := importWordsTxt(dictionary, dst)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmpFile)
+		return err
+	}
+	log.Printf("%v: imported %v words of words.txt into words.db", dictionary.Type(), count)
+	return os.Rename(tmpFile, wordsDb)
+}
+
+func (d *Downloader) download(ctx context.Context, keyword string) (word dict.Word, cached bool, err error) {
+	if *retryFailed {
+		d.forgetFailures(keyword)
+	}
+	entry, exist, err := d.cache.Get(keyword)
+	if err != nil {
+		log.Fatalf("error: cannot read cache: %v", err)
+	}
+	if exist && entry.Expired(*notFoundTTL) && *queryOnline {
+		log.Printf(" recheck: %v [not found since %v]", keyword, entry.FetchedAt.Format("2006-01-02"))
+		exist = false
+	}
+	if !exist {
+		if !*queryOnline {
+			return nil, true, dict.ErrNotFound
+		}
+		word, err = d.lookupOnline(ctx, keyword)
+		if err != nil {
+			return nil, false, err
+		}
+		log.Printf(" lookup ok: %v", word.Word())
+	} else if entry.NotFound {
+		log.Printf(" lookup fail: %v [cache not found]", keyword)
+		return nil, false, dict.ErrNotFound
+	} else {
+		word, err = d.dict.Parse(entry.Json)
+		if err != nil {
+			log.Fatalf("error: cannot unmarshal word from cache: %v, raw: %v", err, string(entry.Json))
+		}
+		log.Printf(" lookup ok: %v [cache]", word.Word())
+	}
+	cached = exist
+	// download mp3/pic
+	if *downloadMp3 {
+		for _, audio := range dict.Audios(word) {
+			if !audioPrefs.allows(word.Type(), audio) {
+				continue
+			}
+			mp3Url := audio.Url
+			mp3Cached, err := d.downloadMp3(ctx, mp3Url)
+			if err != nil {
+				log.Printf("error: cannot download mp3 '%v': %v", mp3Url, err)
+				d.recordFailure(keyword, mp3Url, err)
+			}
+			cached = cached && mp3Cached
+		}
+	}
+	if *downloadPic {
+		for _, picUrl := range dict.Pictures(word) {
+			picCached, err := d.downloadPic(ctx, picUrl)
+			if err != nil {
+				log.Printf("error: cannot download picture '%v': %v", picUrl, err)
+				d.recordFailure(keyword, picUrl, err)
+			}
+			cached = cached && picCached
+		}
+	}
+
+	return word, cached, nil
+}
+
+// lookupOnline queries the dictionary and stores the result in the cache.
+func (d *Downloader) lookupOnline(ctx context.Context, keyword string) (word dict.Word, err error) {
+	err = retry(ctx, keyword, func() error {
+		if err := d.limiter.Wait(ctx); err != nil {
+			return err
+		}
+		lookupCtx, cancel := context.WithTimeout(ctx, d.timeout)
+		defer cancel()
+		var err error
+		word, err = d.dict.LookupContext(lookupCtx, keyword)
+		return err
+	})
+	if errors.Is(err, dict.ErrNotFound) {
+		putErr := d.cache.Put(cache.Entry{
+			NotFound:  true,
+			Reason:    err.Error(),
+			Keyword:   keyword,
+			FetchedAt: time.Now(),
+		}, keyword)
+		if putErr != nil {
+			log.Fatalf("error: cannot write to disk: %v", putErr)
+		}
+		return nil, err
+	} else if err != nil {
+		log.Printf("error: cannot query '%v': %v", keyword, err)
+		d.recordFailure(keyword, "", err)
+		return nil, err
+	}
+	// write to disk
+	err = d.cache.Put(cache.Entry{
+		Json:      []byte(word.Json()),
+		Keyword:   keyword,
+		FetchedAt: time.Now(),
+	}, keyword, word.Word())
+	if err != nil {
+		log.Fatalf("error: cannot write to disk: %v", err)
+	}
+	return word, nil
+}
+
+// downloadMp3 downloads the audio of url into the media store, and
+// processes it with -audio-process. The mp3 of url in the audio directory
+// of the dictionary, stored there by the earlier versions, is added
+// instead.
+func (d *Downloader) downloadMp3(ctx context.Context, url string) (cached bool, err error) {
+	name, cached, err := d.downloadFile(ctx, url, media.KindAudio, filepath.Join(d.audioDir, path.Base(url)))
+	if err == nil && name != "" && *audioProcess {
+		processAudio(name)
+	}
+	return cached, err
+}
+
+// downloadPic downloads the picture of url into the media store, and makes
+// its thumbnail of -pic-max-size, see mediaFile.
+func (d *Downloader) downloadPic(ctx context.Context, url string) (cached bool, err error) {
+	name, cached, err := d.downloadFile(ctx, url, media.KindPicture, "")
+	if err != nil || *picMaxSize <= 0 {
+		return cached, err
+	}
+	thumbnail := mediaStore.VariantPath(name, thumbDir(*picMaxSize))
+	if _, err := os.Stat(thumbnail); err == nil {
+		return cached, nil
+	}
+	// the original picture is exported instead
+	if err := media.Thumbnail(mediaStore.Path(name), thumbnail, *picMaxSize); errors.Is(err, media.ErrNoThumbnail) {
+		log.Printf(" thumbnail: %v [%v]", url, err)
+	} else if err != nil {
+		log.Printf("error: cannot make thumbnail of '%v': %v", url, err)
+	}
+	return cached, nil
+}
+
+// downloadFile downloads url, a file of kind, into the media store and
+// returns the name of its file, unless it is stored. legacyFile is the
+// file of url stored by the earlier versions, it is added to the store if
+// it is valid.
+func (d *Downloader) downloadFile(ctx context.Context, url string, kind media.Kind, legacyFile string) (name string, cached bool, err error) {
+	if url == "" {
+		return "", false, nil
+	}
+	if name, _, ok := mediaStore.File(url); ok {
+		return name, true, nil
+	}
+	if legacyFile != "" {
+		if _, err := os.Stat(legacyFile); err == nil {
+			if err := media.ValidateFile(legacyFile); err != nil {
+				log.Printf(" download again: %v [invalid %v: %v]", url, legacyFile, err)
+			} else {
+				name, err = mediaStore.Add(url, legacyFile, true)
+				return name, true, err
+			}
+		}
+	}
+	if err := os.MkdirAll(mediaStore.TempDir(), 0755); err != nil {
+		return "", false, err
+	}
+	var tmpFile string
+	err = retry(ctx, url, func() error {
+		var err error
+		tmpFile, err = fetchFile(ctx, url, kind, mediaStore.TempDir(), d.timeout)
+		return err
+	})
+	if err != nil {
+		return "", false, err
+	}
+	name, err = mediaStore.Add(url, tmpFile, false)
+	if err != nil {
+		_ = os.Remove(tmpFile)
+		return "", false, err
+	}
+	log.Printf(" download ok: %v", url)
+	return name, false, nil
+}
+
+// fetchFile downloads url, a file of kind, to a new file in dir in one
+// attempt, and returns its path. The response is checked before it is
+// stored: its status, its content type, its size and the magic bytes of
+// its content, so an error page is never taken for the file.
+func fetchFile(ctx context.Context, url string, kind media.Kind, dir string, timeout time.Duration) (_ string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", dict.ClassifyError(0, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", dict.ClassifyError(resp.StatusCode, fmt.Errorf("http status %v", resp.Status))
+	}
+	if err := media.ValidateContentType(kind, resp.Header.Get("Content-Type")); err != nil {
+		return "", err
+	}
+	maxSize := media.MaxSizes[kind]
+	if maxSize > 0 && resp.ContentLength > maxSize {
+		return "", fmt.Errorf("%v bytes, over the limit of %v", resp.ContentLength, maxSize)
+	}
+
+	// a unique temp file, the same file may be downloaded by two workers
+	f, err := os.CreateTemp(dir, "download-*.tmp")
+	if err != nil {
+		return "", err
+	}
+	tmpFile := f.Name()
+	// never leave a partial download behind
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmpFile)
+		}
+	}()
+	body := io.Reader(resp.Body)
+	if maxSize > 0 {
+		// one byte over the limit is enough to reject the file
+		body = io.LimitReader(resp.Body, maxSize+1)
+	}
+	size, err := io.Copy(f, body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", dict.ClassifyError(0, err)
+	}
+	f, err = os.Open(tmpFile)
+	if err != nil {
+		return "", err
+	}
+	head := make([]byte, media.HeadSize)
+	n, _ := io.ReadFull(f, head)
+	_ = f.Close()
+	if err = media.Validate(kind, head[:n], size); err != nil {
+		return "", err
+	}
+	return tmpFile, nil
+}
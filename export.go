@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+	"path/filepath"
+	"word-downloader/anki"
+	"word-downloader/dict"
+)
+
+//go:embed templates/card.css
+var ankiCardCss string
+
+// cardCss is the css of the note types, card.css of the templates
+// directory replaces the default one.
+var cardCss = ankiCardCss
+
+//go:embed templates/card.html
+var cardTemplateText string
+
+// cardTemplate renders the Definition and Pronunciation fields of the cards.
+var cardTemplate = dict.NewTemplate("card", cardTemplateText, nil)
+
+// loadTemplates replaces the default templates and card.css by those in dir.
+func loadTemplates(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	if err := dict.LoadTemplates(dir); err != nil {
+		return err
+	}
+	css, err := os.ReadFile(filepath.Join(dir, "card.css"))
+	if err == nil {
+		cardCss = string(css)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// cardView is the data of the card template.
+type cardView struct {
+	Word  string
+	Dicts []cardDictView
+}
+
+type cardDictView struct {
+	Type dict.Dictionary
+	Html template.HTML
+}
+
+// exporter writes the cards of the looked up words, in the order of the word list.
+type exporter interface {
+	// notes returns the notes of words which add writes
+	notes(words []dict.Word) []ankiNote
+	// add writes the notes of words, ctx cancels the requests of a sync
+	add(ctx context.Context, words []dict.Word) error
+	close() error
+}
+
+// csvExporter writes the notes of the first note type to an anki csv file.
+type csvExporter struct {
+	file     *os.File
+	noteType *noteType
+}
+
+func newCsvExporter(path string) (*csvExporter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	t := noteTypes[0]
+	// the headers of the anki importer, the guid column updates the
+	// notes which are imported before
+	headers := fmt.Sprintf("#separator:Pipe\n#html:true\n#notetype:%v\n#guid column:%v\n", t.Name, len(t.Fields)+1)
+	if _, err := f.WriteString(headers); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &csvExporter{file: f, noteType: t}, nil
+}
+
+func (e *csvExporter) notes(words []dict.Word) []ankiNote {
+	if note, ok := e.noteType.note(words); ok {
+		return []ankiNote{note}
+	}
+	return nil
+}
+
+func (e *csvExporter) add(_ context.Context, words []dict.Word) error {
+	for _, note := range e.notes(words) {
+		if err := writeToAnkiCsv(e.file, note); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *csvExporter) close() error {
+	return e.file.Close()
+}
+
+// apkgExporter collects the notes and writes them to an anki package on close.
+type apkgExporter struct {
+	path string
+	deck anki.Deck
+}
+
+func newApkgExporter(path string, deckName string) *apkgExporter {
+	deck := anki.Deck{Name: deckName, Media: map[string]string{}}
+	for _, t := range noteTypes {
+		deck.Models = append(deck.Models, t.model())
+	}
+	return &apkgExporter{path: path, deck: deck}
+}
+
+func (e *apkgExporter) notes(words []dict.Word) []ankiNote {
+	return buildNotes(words)
+}
+
+func (e *apkgExporter) add(_ context.Context, words []dict.Word) error {
+	for _, note := range e.notes(words) {
+		// only bundle the audio which is downloaded
+		for name, file := range note.media {
+			e.deck.Media[name] = file
+		}
+		note.Model = note.noteType.Name
+		e.deck.Notes = append(e.deck.Notes, note.Note)
+	}
+	return nil
+}
+
+func (e *apkgExporter) close() error {
+	return anki.WriteApkg(e.path, e.deck)
+}
+
+// ankiConnectExporter adds or updates the notes in a running anki.
+type ankiConnectExporter struct {
+	client *anki.Client
+	deck   string
+	// media already uploaded
+	stored         map[string]bool
+	added, updated int
+}
+
+func newAnkiConnectExporter(ctx context.Context, url string, deck string, dryRun bool) (*ankiConnectExporter, error) {
+	client := anki.NewClient(url, nil)
+	client.DryRun = dryRun
+	if err := client.EnsureDeck(ctx, deck); err != nil {
+		return nil, err
+	}
+	for _, t := range noteTypes {
+		if err := client.EnsureModel(ctx, t.model()); err != nil {
+			return nil, err
+		}
+	}
+	return &ankiConnectExporter{client: client, deck: deck, stored: map[string]bool{}}, nil
+}
+
+func (e *ankiConnectExporter) notes(words []dict.Word) []ankiNote {
+	return buildNotes(words)
+}
+
+func (e *ankiConnectExporter) add(ctx context.Context, words []dict.Word) error {
+	for _, note := range e.notes(words) {
+		for name, file := range note.media {
+			if e.stored[name] {
+				continue
+			}
+			if err := e.client.StoreMediaFile(ctx, name, file); err != nil {
+				return err
+			}
+			e.stored[name] = true
+		}
+		added, err := e.client.SyncNote(ctx, e.deck, note.noteType.model(), note.Note)
+		if err != nil {
+			return err
+		}
+		if added {
+			e.added++
+		} else {
+			e.updated++
+		}
+	}
+	return nil
+}
+
+func (e *ankiConnectExporter) close() error {
+	dryRun := ""
+	if e.client.DryRun {
+		dryRun = " (dry run)"
+	}
+	log.Printf("anki connect%v: %v notes added, %v updated", dryRun, e.added, e.updated)
+	return nil
+}
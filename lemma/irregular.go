@@ -0,0 +1,174 @@
+package lemma
+
+// irregular are the irregular forms of the common verbs, nouns and
+// adjectives, by their lemma.
+var irregular = map[string][]string{
+	"be":         {"am", "is", "are", "was", "were", "been", "being"},
+	"have":       {"has", "had", "having"},
+	"do":         {"does", "did", "done", "doing"},
+	"go":         {"goes", "went", "gone", "going"},
+	"arise":      {"arose", "arisen"},
+	"awake":      {"awoke", "awoken"},
+	"bear":       {"bore", "borne", "born"},
+	"beat":       {"beaten"},
+	"become":     {"became"},
+	"begin":      {"began", "begun"},
+	"bend":       {"bent"},
+	"bet":        {"bet"},
+	"bind":       {"bound"},
+	"bite":       {"bit", "bitten"},
+	"bleed":      {"bled"},
+	"blow":       {"blew", "blown"},
+	"break":      {"broke", "broken"},
+	"breed":      {"bred"},
+	"bring":      {"brought"},
+	"build":      {"built"},
+	"burn":       {"burnt"},
+	"buy":        {"bought"},
+	"catch":      {"caught"},
+	"choose":     {"chose", "chosen"},
+	"cling":      {"clung"},
+	"come":       {"came"},
+	"creep":      {"crept"},
+	"deal":       {"dealt"},
+	"dig":        {"dug"},
+	"draw":       {"drew", "drawn"},
+	"dream":      {"dreamt"},
+	"drink":      {"drank", "drunk"},
+	"drive":      {"drove", "driven"},
+	"eat":        {"ate", "eaten"},
+	"fall":       {"fell", "fallen"},
+	"feed":       {"fed"},
+	"feel":       {"felt"},
+	"fight":      {"fought"},
+	"find":       {"found"},
+	"flee":       {"fled"},
+	"fly":        {"flew", "flown", "flies"},
+	"forbid":     {"forbade", "forbidden"},
+	"forget":     {"forgot", "forgotten"},
+	"forgive":    {"forgave", "forgiven"},
+	"freeze":     {"froze", "frozen"},
+	"get":        {"got", "gotten"},
+	"give":       {"gave", "given"},
+	"grind":      {"ground"},
+	"grow":       {"grew", "grown"},
+	"hang":       {"hung"},
+	"hear":       {"heard"},
+	"hide":       {"hid", "hidden"},
+	"hold":       {"held"},
+	"keep":       {"kept"},
+	"kneel":      {"knelt"},
+	"know":       {"knew", "known"},
+	"lay":        {"laid"},
+	"lead":       {"led"},
+	"lean":       {"leant"},
+	"leap":       {"leapt"},
+	"learn":      {"learnt"},
+	"leave":      {"left"},
+	"lend":       {"lent"},
+	"lie":        {"lay", "lain", "lying"},
+	"light":      {"lit"},
+	"lose":       {"lost"},
+	"make":       {"made"},
+	"mean":       {"meant"},
+	"meet":       {"met"},
+	"mistake":    {"mistook", "mistaken"},
+	"overcome":   {"overcame"},
+	"pay":        {"paid"},
+	"ride":       {"rode", "ridden"},
+	"ring":       {"rang", "rung"},
+	"rise":       {"rose", "risen"},
+	"run":        {"ran"},
+	"say":        {"said"},
+	"see":        {"saw", "seen"},
+	"seek":       {"sought"},
+	"sell":       {"sold"},
+	"send":       {"sent"},
+	"shake":      {"shook", "shaken"},
+	"shine":      {"shone"},
+	"shoot":      {"shot"},
+	"show":       {"shown"},
+	"shrink":     {"shrank", "shrunk"},
+	"sing":       {"sang", "sung"},
+	"sink":       {"sank", "sunk"},
+	"sit":        {"sat"},
+	"sleep":      {"slept"},
+	"slide":      {"slid"},
+	"speak":      {"spoke", "spoken"},
+	"speed":      {"sped"},
+	"spend":      {"spent"},
+	"spin":       {"spun"},
+	"spring":     {"sprang", "sprung"},
+	"stand":      {"stood"},
+	"steal":      {"stole", "stolen"},
+	"stick":      {"stuck"},
+	"sting":      {"stung"},
+	"stink":      {"stank", "stunk"},
+	"strike":     {"struck", "stricken"},
+	"strive":     {"strove", "striven"},
+	"swear":      {"swore", "sworn"},
+	"sweep":      {"swept"},
+	"swim":       {"swam", "swum"},
+	"swing":      {"swung"},
+	"take":       {"took", "taken"},
+	"teach":      {"taught"},
+	"tear":       {"tore", "torn"},
+	"tell":       {"told"},
+	"think":      {"thought"},
+	"throw":      {"threw", "thrown"},
+	"tread":      {"trod", "trodden"},
+	"understand": {"understood"},
+	"undertake":  {"undertook", "undertaken"},
+	"wake":       {"woke", "woken"},
+	"wear":       {"wore", "worn"},
+	"weave":      {"wove", "woven"},
+	"weep":       {"wept"},
+	"win":        {"won"},
+	"wind":       {"wound"},
+	"withdraw":   {"withdrew", "withdrawn"},
+	"write":      {"wrote", "written"},
+	"child":      {"children"},
+	"foot":       {"feet"},
+	"goose":      {"geese"},
+	"man":        {"men"},
+	"mouse":      {"mice"},
+	"person":     {"people"},
+	"tooth":      {"teeth"},
+	"woman":      {"women"},
+	"analysis":   {"analyses"},
+	"crisis":     {"crises"},
+	"phenomenon": {"phenomena"},
+	"criterion":  {"criteria"},
+	"good":       {"better", "best"},
+	"bad":        {"worse", "worst"},
+	"far":        {"farther", "further", "farthest", "furthest"},
+}
+
+// ambiguous are the irregular forms which are common lemmas of their
+// own, e.g. "saw" is not always a form of "see".
+var ambiguous = map[string]bool{
+	"am": true, "bore": true, "born": true, "bound": true,
+	"felt": true, "fell": true, "found": true, "ground": true, "lay": true,
+	"left": true, "lit": true, "rose": true, "saw": true, "spoke": true,
+	"stole": true, "tore": true, "wound": true, "fed": true, "shot": true,
+	"bit": true, "best": true, "better": true, "people": true, "sat": true,
+	"ate": true, "bet": true, "got": true, "led": true,
+}
+
+// lemmaOf is the lemma of each irregular form.
+var lemmaOf = func() map[string]string {
+	m := map[string]string{}
+	for lemma, forms := range irregular {
+		for _, form := range forms {
+			if !ambiguous[form] {
+				m[form] = lemma
+			}
+		}
+	}
+	return m
+}()
+
+// IrregularForms returns the irregular forms of lemma, if any.
+func IrregularForms(lemma string) []string {
+	return irregular[lemma]
+}
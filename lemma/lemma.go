@@ -0,0 +1,178 @@
+// Package lemma maps the inflected forms, british spellings and case
+// variants of the words to their lemma, so each lemma is looked up once.
+package lemma
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Lemmatizer finds the lemma of words. The spelling rules only give a
+// lemma which is known, e.g. in a vocabulary or already looked up; the
+// irregular forms and the spellings of the tables always do. The regular
+// inflections are only guessed by Inflects, to check the head word a
+// dictionary answers, since most stems of a suffix are other words, e.g.
+// even of evening.
+type Lemmatizer struct {
+	known func(word string) bool
+	mu    sync.Mutex
+	// mapping are the recorded lemmas, by word
+	mapping map[string]string
+}
+
+// New returns a lemmatizer whose known words are those known reports,
+// nil for none.
+func New(known func(word string) bool) *Lemmatizer {
+	if known == nil {
+		known = func(string) bool { return false }
+	}
+	return &Lemmatizer{known: known, mapping: map[string]string{}}
+}
+
+// Lemma returns the lemma of word, or word in lower case if it has none.
+// The case of a word is kept if only that case is known, e.g. a proper noun.
+func (l *Lemmatizer) Lemma(word string) string {
+	word = strings.TrimSpace(word)
+	lower := strings.ToLower(word)
+	l.mu.Lock()
+	recorded, ok := l.mapping[lower]
+	l.mu.Unlock()
+	if ok {
+		return recorded
+	}
+	if lower != word && l.known(word) && !l.known(lower) {
+		return word
+	}
+	if lemma, ok := lemmaOf[lower]; ok {
+		return lemma
+	}
+	return l.american(lower)
+}
+
+// Inflects tells if lemma is a lemma of word by its irregular forms, its
+// spellings or its regular inflections, e.g. run of running.
+func (l *Lemmatizer) Inflects(word string, lemma string) bool {
+	word = strings.ToLower(strings.TrimSpace(word))
+	lemma = strings.ToLower(strings.TrimSpace(lemma))
+	if word == lemma || lemmaOf[word] == lemma || l.american(word) == lemma {
+		return true
+	}
+	for _, candidate := range Candidates(word) {
+		if candidate == lemma || lemmaOf[candidate] == lemma || britishSpellings[candidate] == lemma {
+			return true
+		}
+	}
+	return false
+}
+
+// Record records lemma as the lemma of word, e.g. the head word the
+// dictionary answers for word. It tells if the mapping is new.
+func (l *Lemmatizer) Record(word string, lemma string) bool {
+	word = strings.ToLower(strings.TrimSpace(word))
+	if word == "" || lemma == "" || word == lemma {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.mapping[word] == lemma {
+		return false
+	}
+	l.mapping[word] = lemma
+	return true
+}
+
+// Load adds the mapping recorded in the json file path, a missing file
+// is empty.
+func (l *Lemmatizer) Load(path string) error {
+	buf, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var mapping map[string]string
+	if err := json.Unmarshal(buf, &mapping); err != nil {
+		return fmt.Errorf("cannot parse %v: %v", path, err)
+	}
+	for word, lemma := range mapping {
+		l.Record(word, lemma)
+	}
+	return nil
+}
+
+// Save writes the recorded mapping to the json file path.
+func (l *Lemmatizer) Save(path string) error {
+	l.mu.Lock()
+	buf, err := json.MarshalIndent(l.mapping, "", " ")
+	l.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmpFile := path + ".tmp"
+	if err := os.WriteFile(tmpFile, buf, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, path)
+}
+
+// Candidates returns the possible lemmas of word by removing its regular
+// suffixes, the most likely first. Most of them are no words at all.
+func Candidates(word string) []string {
+	var candidates []string
+	add := func(stem string, suffix string) {
+		if len(stem) >= 2 && hasVowel(stem) {
+			candidates = append(candidates, stem+suffix)
+		}
+	}
+	n := len(word)
+	for _, suffix := range []string{"ing", "ed", "er", "est"} {
+		if !strings.HasSuffix(word, suffix) {
+			continue
+		}
+		stem := word[:n-len(suffix)]
+		// running, regretted
+		if len(stem) >= 3 && stem[len(stem)-1] == stem[len(stem)-2] && !strings.ContainsRune("lsfz", rune(stem[len(stem)-1])) {
+			add(stem[:len(stem)-1], "")
+		}
+		if suffix != "ing" && strings.HasSuffix(stem, "i") {
+			// carried, happiest
+			add(stem[:len(stem)-1], "y")
+		}
+		if suffix == "ing" && strings.HasSuffix(stem, "y") {
+			// lying
+			add(stem[:len(stem)-1], "ie")
+		}
+		add(stem, "e")
+		add(stem, "")
+		if strings.HasSuffix(stem, "ck") {
+			// panicked
+			add(stem[:len(stem)-1], "")
+		}
+	}
+	switch {
+	case strings.HasSuffix(word, "ies"):
+		add(word[:n-3], "y")
+	case strings.HasSuffix(word, "ves"):
+		add(word[:n-3], "f")
+		add(word[:n-3], "fe")
+	}
+	// uses, then watches
+	if strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") {
+		add(word[:n-1], "")
+	}
+	if strings.HasSuffix(word, "es") {
+		add(word[:n-2], "")
+	}
+	return candidates
+}
+
+func hasVowel(s string) bool {
+	return strings.ContainsAny(s, "aeiouy")
+}
@@ -0,0 +1,90 @@
+package lemma
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLemmatizer_Lemma(t *testing.T) {
+	known := map[string]bool{
+		"run": true, "regret": true, "carry": true, "make": true, "hope": true,
+		"hop": true, "watch": true, "organize": true, "news": true, "Alpine": true,
+		"visit": true, "happy": true, "spell": true,
+	}
+	l := New(func(word string) bool { return known[word] })
+	for word, want := range map[string]string{
+		"ran":      "run",
+		"children": "child",
+		"colour":   "color",
+		"organise": "organize",
+		"REGRET":   "regret",
+		"Alpine":   "Alpine",
+		"saw":      "saw",
+		"give up":  "give up",
+		// the regular inflections are not guessed
+		"running":  "running",
+		"kestrels": "kestrels",
+		"evening":  "evening",
+		"news":     "news",
+		"uses":     "uses",
+	} {
+		if got := l.Lemma(word); got != want {
+			t.Errorf("Lemma(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestLemmatizer_Inflects(t *testing.T) {
+	l := New(nil)
+	for _, test := range []struct {
+		word, lemma string
+		want        bool
+	}{
+		{"running", "run", true},
+		{"Runs", "run", true},
+		{"regretted", "regret", true},
+		{"carried", "carry", true},
+		{"making", "make", true},
+		{"hopped", "hop", true},
+		{"watches", "watch", true},
+		{"uses", "use", true},
+		{"happiest", "happy", true},
+		{"ran", "run", true},
+		{"colours", "color", true},
+		{"kestrels", "falcon", false},
+		{"worse", "bad", true},
+		{"organised", "organize", true},
+	} {
+		if got := l.Inflects(test.word, test.lemma); got != test.want {
+			t.Errorf("Inflects(%q, %q) = %v, want %v", test.word, test.lemma, got, test.want)
+		}
+	}
+	if candidates := Candidates("uses"); candidates[0] != "use" {
+		t.Errorf("want use first, got: %q", candidates)
+	}
+}
+
+func TestLemmatizer_Record(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lemmas.json")
+	l := New(nil)
+	if got := l.Lemma("kestrels"); got != "kestrels" {
+		t.Fatalf("want no lemma of an unknown word, got: %v", got)
+	}
+	if !l.Record("Kestrels", "kestrel") || l.Record("kestrels", "kestrel") {
+		t.Fatalf("want a new mapping once")
+	}
+	if err := l.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := New(nil)
+	if err := loaded.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.Lemma("kestrels"); got != "kestrel" {
+		t.Fatalf("want the recorded lemma, got: %v", got)
+	}
+	if err := New(nil).Load(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Fatalf("want a missing file empty, got: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package lemma
+
+import "strings"
+
+// britishSpellings are the american spellings of the british words which
+// are not given by the rules of spellingRules.
+var britishSpellings = map[string]string{
+	"aeroplane":  "airplane",
+	"aluminium":  "aluminum",
+	"defence":    "defense",
+	"grey":       "gray",
+	"jewellery":  "jewelry",
+	"judgement":  "judgment",
+	"licence":    "license",
+	"manoeuvre":  "maneuver",
+	"mould":      "mold",
+	"moult":      "molt",
+	"offence":    "offense",
+	"plough":     "plow",
+	"practise":   "practice",
+	"pretence":   "pretense",
+	"programme":  "program",
+	"pyjamas":    "pajamas",
+	"sceptic":    "skeptic",
+	"sceptical":  "skeptical",
+	"ageing":     "aging",
+	"enquire":    "inquire",
+	"enquiry":    "inquiry",
+	"catalogue":  "catalog",
+	"dialogue":   "dialog",
+	"colour":     "color",
+	"favour":     "favor",
+	"honour":     "honor",
+	"humour":     "humor",
+	"labour":     "labor",
+	"neighbour":  "neighbor",
+	"behaviour":  "behavior",
+	"flavour":    "flavor",
+	"harbour":    "harbor",
+	"rumour":     "rumor",
+	"vapour":     "vapor",
+	"centre":     "center",
+	"metre":      "meter",
+	"theatre":    "theater",
+	"fibre":      "fiber",
+	"litre":      "liter",
+	"organise":   "organize",
+	"realise":    "realize",
+	"recognise":  "recognize",
+	"apologise":  "apologize",
+	"analyse":    "analyze",
+	"paralyse":   "paralyze",
+	"travelled":  "traveled",
+	"travelling": "traveling",
+	"cancelled":  "canceled",
+	"cancelling": "canceling",
+}
+
+// spellingRules replace the british suffixes by the american ones, the
+// result is only taken if it is a known word.
+var spellingRules = []struct{ british, american string }{
+	{"isation", "ization"},
+	{"ise", "ize"},
+	{"ised", "ized"},
+	{"ising", "izing"},
+	{"yse", "yze"},
+	{"our", "or"},
+	{"ours", "ors"},
+	{"re", "er"},
+	{"res", "ers"},
+	{"ogue", "og"},
+	{"ence", "ense"},
+	{"lled", "led"},
+	{"lling", "ling"},
+}
+
+// american returns the american spelling of word, word itself if it has
+// none.
+func (l *Lemmatizer) american(word string) string {
+	if american, ok := britishSpellings[word]; ok {
+		return american
+	}
+	if l.known(word) {
+		return word
+	}
+	for _, rule := range spellingRules {
+		if !strings.HasSuffix(word, rule.british) {
+			continue
+		}
+		american := strings.TrimSuffix(word, rule.british) + rule.american
+		if l.known(american) {
+			return american
+		}
+	}
+	return word
+}
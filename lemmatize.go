@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"word-downloader/dict"
+	"word-downloader/lemma"
+)
+
+// lemmatizer maps the words of the word list to their lemma before they
+// are looked up, nil unless -lemmatize.
+var lemmatizer *lemma.Lemmatizer
+
+// lemmasPath is the file of the lemmas recorded by the lemmatizer.
+func lemmasPath() string {
+	return filepath.Join(*dataDir, "lemmas.json")
+}
+
+// newLemmatizer returns a lemmatizer whose known words are those of the
+// -vocabulary file and those found in the caches of downloaders, with the
+// lemmas recorded by the earlier runs.
+func newLemmatizer(downloaders []*Downloader) (*lemma.Lemmatizer, error) {
+	vocabulary := map[string]bool{}
+	if *vocabularyPath != "" {
+		words, err := readVocabulary(*vocabularyPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, word := range words {
+			vocabulary[word] = true
+		}
+	}
+	l := lemma.New(func(word string) bool {
+		if vocabulary[word] {
+			return true
+		}
+		for _, d := range downloaders {
+			entry, exist, err := d.cache.Get(word)
+			if err == nil && exist && !entry.NotFound {
+				return true
+			}
+		}
+		return false
+	})
+	if err := l.Load(lemmasPath()); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// readVocabulary reads the first column of each line of path.
+func readVocabulary(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var words []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 0 {
+			words = append(words, fields[0])
+		}
+	}
+	return words, scanner.Err()
+}
+
+// lemmaJobs maps the keyword of each job to its lemma. A job whose lemma
+// is already looked up for another keyword, or is skipped, e.g. a known
+// word, is dropped. The indexes are renumbered.
+func lemmaJobs(in <-chan lookupJob, skip func(word string) bool) <-chan lookupJob {
+	out := make(chan lookupJob)
+	go func() {
+		defer close(out)
+		// the first keyword of each lemma
+		firstKeyword := map[string]string{}
+		count := 0
+		for job := range in {
+			job.original = job.keyword
+			job.keyword = lemmatizer.Lemma(job.keyword)
+			if job.keyword != job.original {
+				log.Printf(" lemma: %v -> %v", job.original, job.keyword)
+			}
+			if job.keyword != job.original && skip != nil && skip(job.keyword) {
+				log.Printf(" skip: %v [%v]", job.original, wordStatuses.Get(job.keyword))
+				continue
+			}
+			keyword := strings.ToLower(job.original)
+			if first, ok := firstKeyword[job.keyword]; ok && first != keyword {
+				log.Printf(" skip: %v [same lemma as %v]", job.original, first)
+				continue
+			}
+			firstKeyword[job.keyword] = keyword
+			job.index = count
+			out <- job
+			count++
+		}
+	}()
+	return out
+}
+
+// recordLemma records the head word of words as the lemma of the keywords
+// of job, so they are not looked up again. A head word which is not a
+// lemma of the keyword, e.g. a related word, is not recorded.
+func recordLemma(job lookupJob, words []dict.Word) {
+	if lemmatizer == nil || len(words) == 0 {
+		return
+	}
+	headword := words[0].Word()
+	if strings.EqualFold(headword, job.keyword) || !lemmatizer.Inflects(job.original, headword) {
+		return
+	}
+	if lemmatizer.Inflects(job.keyword, headword) {
+		lemmatizer.Record(job.keyword, headword)
+	}
+	if lemmatizer.Record(job.original, headword) {
+		log.Printf(" lemma: %v -> %v [%v]", job.original, headword, words[0].Type().Name())
+	}
+}
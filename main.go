@@ -1,61 +1,161 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"io"
 	"log"
-	"net/http"
 	"os"
-	"path"
+	"os/signal"
 	"path/filepath"
 	"sort"
 	"strings"
+	"syscall"
 	"time"
+	"word-downloader/anki"
 	"word-downloader/dict"
-	"word-downloader/dict/bingdict"
-	"word-downloader/dict/collins"
-	"word-downloader/dict/dictcn"
-	"word-downloader/dict/webster"
+	"word-downloader/wordlist"
+
+	"golang.org/x/time/rate"
 )
 
 var wordList = flag.String("word-list", "", "word list, if empty, read from stdio")
-var dictionary = flag.String("dicts", "webster", "dictionary, comma separated. support: webster, dictcn, collins")
-var sleepInterval = flag.Int64("sleep-interval", 1, "number of seconds to sleep before downloading next word")
+var wordListFormat = flag.String("word-list-format", wordlist.FormatAuto, "format of the word list: "+wordlist.FormatAuto+" (by the file extension), "+strings.Join(wordlist.Formats(), ", "))
+var csvColumn = flag.String("csv-column", "1", "column of a csv word list, its 1-based index or its name in the header line")
+var dictionary = flag.String("dicts", "webster", "dictionary, comma separated. support: "+strings.Join(dictNames(), ", "))
+var sleepInterval = flag.Int64("sleep-interval", 1, "number of seconds between two online lookups of a dictionary, unless set by -rate")
+var rates = flag.String("rate", "", "online lookup rate per dictionary, comma separated, e.g. webster=2/s,dictcn=30/m")
+var concurrency = flag.Int("concurrency", 1, "number of words looked up at the same time")
 var ankiCsv = flag.Bool("anki", false, "generate anki-flash csv file")
 var downloadMp3 = flag.Bool("download-mp3", true, "whether download mp3")
+var downloadPic = flag.Bool("download-pic", false, "whether download the pictures of the dictionaries which have them, e.g. bing-dict")
+var audioAccents = flag.String("audio-accents", "", "accents of the audio in the order of preference, comma separated: us, uk. the audio of the other accents is not downloaded nor exported, empty for all")
+var audioVoices = flag.String("audio-voices", "female", "voices of the audio in the order of preference, comma separated: female, male. empty for all")
+var audioDicts = flag.String("audio-dicts", "", "dictionaries of the audio in the order of preference, comma separated, empty for all in the order of -dicts")
+var audioClips = flag.Int("audio-clips", 1, "number of sounds of an audio field of the cards, the best of each accent first, e.g. uk then us")
+var audioProcess = flag.Bool("audio-process", false, "trim the silence of the downloaded audio and normalize its loudness, to a wav used by the anki export. the originals are kept")
+var audioLoudness = flag.Float64("audio-loudness", -20, "loudness of the processed audio in dBFS, the rms of its sound")
+var audioSilence = flag.Float64("audio-silence", -50, "level of the silence trimmed from the processed audio in dBFS")
+var picMaxSize = flag.Int("pic-max-size", 320, "max width and height of the thumbnails of the pictures used by the anki export, 0 for the original pictures")
 var queryOnline = flag.Bool("query-online", true, "query online when missing")
+var requestTimeout = flag.Duration("timeout", time.Minute, "deadline of each lookup or download request")
+var retries = flag.Int("retries", 3, "number of retries of a rate limited or transient failure")
+var retryBackoff = flag.Duration("retry-backoff", 2*time.Second, "delay before the first retry, doubled for each next retry")
+var cacheType = flag.String("cache", "bolt", "cache of looked up words: bolt (words.db) or file (words.txt). words.txt is imported into words.db the first time")
+var notFoundTTL = flag.Duration("not-found-ttl", 0, "query a word again once it is not found for this long, e.g. 720h. 0 never queries again")
+var retryFailed = flag.Bool("retry-failed", false, "instead of the word list, retry the words recorded in audio-error.txt")
+var configPath = flag.String("config", "", "yaml config file of the dictionaries, paths and exports, see config.example.yaml. flags override its values")
+var dataDir = flag.String("data-dir", ".", "root directory of the cache and media of each dictionary")
+var ankiCsvPath = flag.String("anki-file", "anki-flashcard.csv", "path of the anki csv file generated by -anki")
+var ankiApkg = flag.String("anki-apkg", "", "write an anki package (.apkg) with the note type and the referenced audio to this path")
+var ankiDeck = flag.String("anki-deck", "word-downloader", "deck name of -anki-apkg and -anki-connect")
+var ankiConnect = flag.String("anki-connect", "", "add or update the cards in a running anki through AnkiConnect at this url, e.g. "+anki.DefaultConnectUrl)
+var ankiCloze = flag.Bool("anki-cloze", false, "also export cloze notes, which mask the word in its best example sentences")
+var ankiConnectDryRun = flag.Bool("anki-connect-dry-run", false, "only log the changes -anki-connect would make")
+var incremental = flag.Bool("incremental", false, "only export the cards which are new or changed since the last export to the same target, see export-manifest.json")
+var templatesDir = flag.String("templates", "", "directory of the templates replacing the default ones: <dictionary>.html, card.html and card.css. see the templates command")
+var lemmatize = flag.Bool("lemmatize", false, "look up the lemma of the irregular forms, british spellings and case variants of the words, e.g. run for ran, and record the lemmas of the inflected forms answered by the dictionaries, e.g. run for running")
+var vocabularyPath = flag.String("vocabulary", "", "word list of the known lemmas of -lemmatize, the first column of each line. the looked up words are known as well")
+var dedupe = flag.Bool("dedupe", false, "look up each word of the word list once")
+var lowercase = flag.Bool("lowercase", false, "put the words of the word list in lower case")
+var alphaOnly = flag.Bool("alpha-only", false, "strip the punctuation around the words of the word list and skip those which are not made of letters")
+var skipProperNouns = flag.Bool("skip-proper-nouns", false, "skip the capitalized words of the word list, unless it has them in lower case as well, or -frequency-list keeps the case of the words and has them more often in lower case")
+var frequencyList = flag.String("frequency-list", "", "word frequency list of 'word count' lines, e.g. word-list/sorted.txt, for -min-frequency, -top and -skip-proper-nouns")
+var minFrequency = flag.Int("min-frequency", 0, "skip the words of the word list whose count in -frequency-list is less")
+var top = flag.Int("top", 0, "only look up the top most frequent words of the word list, by their count in -frequency-list. 0 for all")
+var skipStatus = flag.String("skip-status", "known,ignored", "skip the words of the word list with these statuses, comma separated: new, learning, known or ignored. see the status command")
+var collinsBackend = flag.String("collins-backend", "http", "how collins pages are fetched: http, selenium, or fallback (selenium once http is blocked)")
 
-var ankiDictScore = map[dict.Dictionary]int{
-	dict.Collins:  0,
-	dict.Webster:  1,
-	dict.Dictcn:   2,
-	dict.BingDict: 3,
+func usage() {
+	out := flag.CommandLine.Output()
+	_, _ = fmt.Fprintf(out, "Usage: %v [flags] [command]\n\n", os.Args[0])
+	_, _ = fmt.Fprintf(out, "Without a command, look up the word list.\n\nCommands:\n")
+	_, _ = fmt.Fprintf(out, "  migrate-cache\timport words.txt of each dictionary into words.db\n")
+	_, _ = fmt.Fprintf(out, "  recheck\tquery the not found words of each dictionary again\n")
+	_, _ = fmt.Fprintf(out, "  config validate\tcheck the -config file\n")
+	_, _ = fmt.Fprintf(out, "  media process\tprocess the downloaded audio which is not yet, see -audio-process\n")
+	_, _ = fmt.Fprintf(out, "  media verify\tremove the invalid audio and pictures, e.g. error pages, and download them again\n")
+	_, _ = fmt.Fprintf(out, "  status import <status> <file>...\tset the status of the words of a word list or anki export: new, learning, known or ignored\n")
+	_, _ = fmt.Fprintf(out, "  status set <status> <word>...\tset the status of the words\n")
+	_, _ = fmt.Fprintf(out, "  status list [status]\tprint the words and their status, see word-status.json\n")
+	_, _ = fmt.Fprintf(out, "  status mark\task the status of the words of -word-list which have none\n")
+	_, _ = fmt.Fprintf(out, "  templates <dir>\twrite the default templates to dir, to be edited and used by -templates\n")
+	_, _ = fmt.Fprintf(out, "\nFlags:\n")
+	flag.PrintDefaults()
 }
 
 func main() {
+	flag.Usage = usage
 	flag.Parse()
 
+	// the first interrupt cancels the requests in flight and stops the run,
+	// which saves what is done so far; the second one kills
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if flag.Arg(0) == "config" {
+		configCommand(flag.Arg(1))
+		return
+	}
+	setupConfig()
+	if flag.Arg(0) == "templates" {
+		writeTemplates(flag.Arg(1))
+		return
+	}
+	if flag.Arg(0) == "status" {
+		statusCommand(flag.Args()[1:])
+		return
+	}
+	if err := loadTemplates(*templatesDir); err != nil {
+		log.Fatalf("error: cannot load templates: %v", err)
+	}
+	if err := setupAudioPreference(); err != nil {
+		log.Fatalf("error: %v", err)
+	}
+	if err := setupNoteTypes(appConfig.Export.NoteTypes, *ankiCloze); err != nil {
+		log.Fatalf("error: %v", err)
+	}
+
 	var myDicts []dict.Dict
 	for _, dictName := range strings.Split(*dictionary, ",") {
 		if dictName == "" {
 			continue
 		}
-		switch dict.Dictionary(dictName) {
-		case dict.Webster:
-			myDicts = append(myDicts, webster.NewDict())
-		case dict.BingDict:
-			myDicts = append(myDicts, bingdict.NewBingDict())
-		case dict.Dictcn:
-			myDicts = append(myDicts, dictcn.NewDict())
-		case dict.Collins:
-			myDicts = append(myDicts, collins.NewDict())
-		default:
-			_, _ = fmt.Fprintf(os.Stderr, "unsuported dictionary: %v", *dictionary)
+		myDict, err := newDict(appConfig.dictConfig(dict.Dictionary(dictName)))
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "%v\n", err)
 			flag.PrintDefaults()
 			os.Exit(1)
 		}
+		myDicts = append(myDicts, myDict)
+	}
+
+	if err := openMediaStore(); err != nil {
+		log.Fatalf("error: cannot open media store: %v", err)
+	}
+	switch flag.Arg(0) {
+	case "":
+	case "media":
+		mediaCommand(ctx, myDicts, flag.Arg(1))
+		return
+	case "migrate-cache":
+		migrateCache(myDicts)
+		return
+	case "recheck":
+		recheckNotFound(ctx, myDicts)
+		return
+	default:
+		_, _ = fmt.Fprintf(os.Stderr, "unknown command: %v\n", flag.Arg(0))
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if *concurrency < 1 {
+		*concurrency = 1
 	}
 
 	postAction := PostAction("")
@@ -63,280 +163,181 @@ func main() {
 		postAction = AnCsv
 	}
 
-	var ankiFile *os.File
+	manifest, err := loadManifest(filepath.Join(*dataDir, "export-manifest.json"))
+	if err != nil {
+		log.Fatalf("error: cannot read export manifest: %v", err)
+	}
+	var exporters []exporter
 	if postAction == AnCsv {
-		var err error
-		ankiFile, err = os.Create("anki-flashcard.csv")
+		csv, err := newCsvExporter(*ankiCsvPath)
 		if err != nil {
-			log.Fatalf("error: cannot create anki-flashcard.csv: %v", err)
+			log.Fatalf("error: cannot create %v: %v", *ankiCsvPath, err)
 		}
+		exporters = append(exporters, newManifestExporter("csv:"+*ankiCsvPath, csv, manifest, *incremental))
 	}
-	defer ankiFile.Close()
-
-	var wordSourceFile *os.File
-	if *wordList == "" {
-		wordSourceFile = os.Stdin
-	} else {
-		f, err := os.Open(*wordList)
+	if *ankiApkg != "" {
+		apkg := newApkgExporter(*ankiApkg, *ankiDeck)
+		exporters = append(exporters, newManifestExporter("apkg:"+*ankiApkg, apkg, manifest, *incremental))
+	}
+	if *ankiConnect != "" {
+		connect, err := newAnkiConnectExporter(ctx, *ankiConnect, *ankiDeck, *ankiConnectDryRun)
 		if err != nil {
-			log.Fatalf("cannot open word list file: %v", err)
-			return
+			log.Fatalf("error: cannot sync with anki: %v", err)
+		}
+		var target exporter = connect
+		if !*ankiConnectDryRun {
+			target = newManifestExporter("anki-connect:"+*ankiDeck, connect, manifest, *incremental)
 		}
-		wordSourceFile = f
-		defer f.Close()
+		exporters = append(exporters, target)
 	}
 
-	sort.Slice(myDicts, func(i, j int) bool {
-		return ankiDictScore[myDicts[i].Type()] < ankiDictScore[myDicts[j].Type()]
-	})
-	var downloaders []*Downloader
-	for _, dict := range myDicts {
-		downloaders = append(downloaders, newDownloader(dict))
+	downloaders := newDownloaders(myDicts)
+	for _, downloader := range downloaders {
+		defer downloader.close()
 	}
 
-	count := 0
-	bufInput := bufio.NewReader(wordSourceFile)
-	for {
-		wordBytes, err := bufInput.ReadBytes('\n')
-		if err != nil && err != io.EOF {
-			log.Fatalf("error: failed to read word: %v", err)
-			break
-		}
-		if len(wordBytes) > 0 {
-			noWait := true
-			var words []dict.Word
-			for _, downloader := range downloaders {
-				word, wordCached, err := downloader.download(strings.TrimSpace(string(wordBytes)))
-				noWait = (noWait && wordCached) || err == dict.ErrNotFound
-				if err == nil {
+	var words []string
+	if *retryFailed {
+		seen := map[string]bool{}
+		for _, downloader := range downloaders {
+			for _, word := range downloader.failedWords() {
+				if !seen[word] {
+					seen[word] = true
 					words = append(words, word)
 				}
 			}
-
-			// write to anki csv file
-			if len(words) > 0 && ankiFile != nil {
-				writeToAnkiCsv(ankiFile, words)
-			}
-			log.Printf("finish: %v", count)
-			if !noWait {
-				time.Sleep(time.Second * time.Duration(*sleepInterval))
+		}
+		log.Printf("retry %v failed words", len(words))
+	} else {
+		var wordSource io.Reader = os.Stdin
+		if *wordList != "" {
+			f, err := os.Open(*wordList)
+			if err != nil {
+				log.Fatalf("cannot open word list file: %v", err)
+				return
 			}
-			count++
+			wordSource = f
+			defer f.Close()
 		}
+		words, err = readWords(wordSource)
 		if err != nil {
-			break
+			log.Fatalf("error: cannot read word list: %v", err)
 		}
 	}
-}
-
-type Downloader struct {
-	dict         dict.Dict
-	audioDir     string
-	picDir       string
-	audioErrFile *os.File
-	words        *os.File
-	existWords   map[string]dict.Word
-	//
-	ankiFile *os.File
-}
-
-type PostAction string
-
-const (
-	AnCsv PostAction = "anki-csv"
-)
-
-func newDownloader(dict dict.Dict) *Downloader {
-	myDictDir := string(dict.Type())
-	err := os.MkdirAll(myDictDir, 0755)
+	wordStatuses, err = wordlist.LoadStatuses(statusesPath())
 	if err != nil {
-		log.Fatalf("error: cannot mkdir: %v", err)
-		return nil
-	}
-	downloader := &Downloader{
-		dict:     dict,
-		audioDir: filepath.Join(myDictDir, "audio"),
-		picDir:   filepath.Join(myDictDir, "pic"),
+		log.Fatalf("error: cannot read word statuses: %v", err)
 	}
-
-	err = os.MkdirAll(downloader.audioDir, 0755)
+	filter, err := newWordFilter()
 	if err != nil {
-		log.Fatalf("error: cannot mkdir audio: %v", err)
-		return nil
+		log.Fatalf("error: %v", err)
 	}
-
-	err = os.MkdirAll(downloader.picDir, 0755)
-	if err != nil {
-		log.Fatalf("error: cannot mkdir audio: %v", err)
-		return nil
-	}
-
-	words, err := os.OpenFile(filepath.Join(myDictDir, "words.txt"), os.O_RDWR|os.O_CREATE, 0644)
-	if err != nil {
-		log.Fatalf("error: cannot create words.txt: %v", err)
+	if filter.Exclude, err = statusFilter(); err != nil {
+		log.Fatalf("error: %v", err)
 	}
-	downloader.words = words
-	// read all finished
-	downloader.loadAllFinished()
-
-	errFile, err := os.OpenFile(filepath.Join(myDictDir, "audio-error.txt"), os.O_RDWR|os.O_APPEND|os.O_CREATE, 0644)
-	if err != nil {
-		log.Fatalf("error: cannot create audio-error.txt: %v", err)
+	if filtered := filter.Apply(words); len(filtered) != len(words) {
+		log.Printf("%v of %v words kept by the preprocessing", len(filtered), len(words))
+		words = filtered
 	}
-	downloader.audioErrFile = errFile
 
-	return downloader
-}
-
-func (d *Downloader) loadAllFinished() {
-	d.existWords = map[string]dict.Word{}
-	bufio := bufio.NewReader(d.words)
-	var fileErr error
-	for fileErr == nil {
-		line, fileErr := bufio.ReadBytes('\n')
-		if fileErr != nil && fileErr != io.EOF {
-			log.Fatalf("error: cannot read from words.txt: %v", fileErr)
-		}
-		if len(line) == 0 {
-			break
-		}
-		if strings.HasPrefix(string(line), "__not_found:") {
-			word := strings.TrimPrefix(string(line), "__not_found:")
-			word = strings.TrimSpace(word)
-			d.existWords[word] = nil
-			continue
+	jobs := make(chan lookupJob)
+	go func() {
+		defer close(jobs)
+		for i, word := range words {
+			select {
+			case jobs <- lookupJob{index: i, keyword: word}:
+			case <-ctx.Done():
+				return
+			}
 		}
-		word, err := d.dict.Parse(line)
+	}()
+
+	var lookupJobs <-chan lookupJob = jobs
+	if *lemmatize {
+		lemmatizer, err = newLemmatizer(downloaders)
 		if err != nil {
-			log.Fatalf("error: cannot unmarshal word from words.txt: %v, raw: %v", err, string(line))
+			log.Fatalf("error: cannot load lemmas: %v", err)
 		}
-		d.existWords[word.Word()] = word
+		lookupJobs = lemmaJobs(jobs, filter.Exclude)
 	}
-}
 
-func (d *Downloader) download(keyword string) (word dict.Word, cached bool, err error) {
-	word, exist := d.existWords[keyword]
-	if !exist {
-		if !*queryOnline {
-			return nil, true, dict.ErrNotFound
-		}
-		word, err = d.dict.Lookup(keyword)
-		if err == dict.ErrNotFound {
-			_, err = d.words.WriteString("__not_found:" + keyword + "\n")
-			if err != nil {
-				log.Fatalf("error: cannot write to disk: %v", err)
-			}
-			return nil, false, dict.ErrNotFound
-		} else if err != nil {
-			log.Printf("error: cannot query '%v': %v", keyword, err)
-			return nil, false, err
-		}
-		log.Printf(" lookup ok: %v", word.Word())
-	} else if word == nil {
-		log.Printf(" lookup fail: %v [cache not found]", keyword)
-		return nil, false, dict.ErrNotFound
-	} else {
-		log.Printf(" lookup ok: %v [cache]", word.Word())
+	// write to anki csv file
+	count := writeResults(ctx, runWorkers(ctx, *concurrency, downloaders, lookupJobs), exporters)
+	if ctx.Err() != nil {
+		log.Printf("interrupted, stop at: %v", count)
 	}
-	cached = exist
-	// write to disk
-	if !exist {
-		_, err = d.words.WriteString(word.Json() + "\n")
-		if err != nil {
-			log.Fatalf("error: cannot write to disk: %v", err)
+	for _, e := range exporters {
+		if err := e.close(); err != nil {
+			log.Printf("error: cannot export: %v", err)
 		}
-		d.existWords[keyword] = word
-		d.existWords[word.Word()] = word
 	}
-	// download mp3/pic
-	if *downloadMp3 {
-		for _, mp3Url := range word.Mp3() {
-			mp3Cached, err := d.downloadMp3(mp3Url)
-			if err != nil {
-				log.Printf("error: cannot download mp3 '%v': %v", mp3Url, err)
-			}
-			cached = cached && mp3Cached
+	if lemmatizer != nil {
+		if err := lemmatizer.Save(lemmasPath()); err != nil {
+			log.Printf("error: cannot write lemmas: %v", err)
 		}
 	}
-
-	return word, cached, nil
-}
-
-func (d *Downloader) downloadMp3(url string) (cached bool, err error) {
-	storeName := path.Base(url)
-	return d.downloadFile(url, filepath.Join(d.audioDir, storeName))
-}
-
-func (d *Downloader) downloadPic(url string) (cached bool, err error) {
-	storeName := path.Base(url)
-	return d.downloadFile(url, filepath.Join(d.picDir, storeName))
-}
-
-func (d *Downloader) downloadFile(url string, storeName string) (cached bool, err error) {
-	if url == "" {
-		return false, nil
+	if err := wordStatuses.Save(statusesPath()); err != nil {
+		log.Printf("error: cannot write word statuses: %v", err)
 	}
-	_, err = os.Stat(storeName)
-	if err == nil {
-		return true, nil
+	if err := mediaStore.Save(); err != nil {
+		log.Printf("error: cannot write media index: %v", err)
 	}
-	tmpFile := storeName + ".tmp"
-	f, err := os.Create(tmpFile)
-	if err != nil {
-		return false, err
+	if len(exporters) > 0 {
+		if err := manifest.save(); err != nil {
+			log.Printf("error: cannot write export manifest: %v", err)
+		}
 	}
-	resp, err := http.Get(url)
+}
+
+// newDownloaders creates a downloader with the rate limit of -rate, or else
+// of the config, for each dictionary, in the order of their priority.
+func newDownloaders(myDicts []dict.Dict) []*Downloader {
+	dictRates, err := parseRates(*rates)
 	if err != nil {
-		return false, err
+		_, _ = fmt.Fprintf(os.Stderr, "%v\n", err)
+		flag.PrintDefaults()
+		os.Exit(1)
 	}
-	defer resp.Body.Close()
-	_, err = io.Copy(f, resp.Body)
-	if err != nil {
-		_ = f.Close()
-		return false, err
+	defaultRate := rate.Inf
+	if *sleepInterval > 0 {
+		defaultRate = rate.Every(time.Second * time.Duration(*sleepInterval))
 	}
-	_ = f.Close()
-	log.Printf(" download ok: %v", url)
-	return false, os.Rename(tmpFile, storeName)
-}
-
-func writeToAnkiCsv(ankiFile *os.File, words []dict.Word) error {
-	// word | pronunciation | example | word_html | sound
 
-	var plainWord string
-
-	defSb := strings.Builder{}
-	defSb.WriteString(`<div class="background_card">`)
-	var mp3 string
-	var pronunciation string
-	for _, word := range words {
-		if plainWord == "" {
-			plainWord = word.Word()
-			pronunciation = fmt.Sprintf(`<div class="pronunciation">%v</div>`, word.Pronunciation())
-			defSb.WriteString(`<div class="this-word">`)
-			defSb.WriteString(plainWord)
-			defSb.WriteString(`</div>`)
+	sort.Slice(myDicts, func(i, j int) bool {
+		return myDicts[i].Type().Priority() < myDicts[j].Type().Priority()
+	})
+	var downloaders []*Downloader
+	for _, dict := range myDicts {
+		dc := appConfig.dictConfig(dict.Type())
+		limit, ok := dictRates[dict.Type()]
+		if !ok && dc.Rate != "" {
+			limit, _ = parseRate(dc.Rate)
+		} else if !ok {
+			limit = defaultRate
 		}
-		defSb.WriteString(fmt.Sprintf(`<div class="dict %v">%v</div>`, word.Type(), word.DefinitionHtml(false)))
-		if word.Type() == dict.Webster {
-			mp3List := word.Mp3()
-			if len(mp3List) > 0 {
-				mp3 = path.Base(mp3List[0])
-			}
+		timeout := *requestTimeout
+		if dc.Timeout > 0 {
+			timeout = dc.Timeout
 		}
+		downloaders = append(downloaders, newDownloader(dict, rate.NewLimiter(limit, 1), timeout))
 	}
-	defSb.WriteString(`</div>`)
+	return downloaders
+}
+
+type PostAction string
 
+const (
+	AnCsv PostAction = "anki-csv"
+)
+
+func writeToAnkiCsv(ankiFile *os.File, note ankiNote) error {
+	// the fields of the note type | guid
 	sb := strings.Builder{}
-	sb.WriteString(plainWord)
-	sb.WriteString("|")
-	sb.WriteString(escapeVerticalBar(pronunciation))
-	sb.WriteString("|")
-	sb.WriteString("")
-	sb.WriteString("|")
-	sb.WriteString(fmt.Sprintf(`<div class="word">%v</div>`, escapeVerticalBar(defSb.String())))
-	sb.WriteString("|")
-	sb.WriteString(fmt.Sprintf(`[sound:%v]`, mp3))
+	for _, field := range note.Fields {
+		sb.WriteString(escapeVerticalBar(field))
+		sb.WriteString("|")
+	}
+	sb.WriteString(note.Guid)
 	sb.WriteString("\n")
 	_, err := ankiFile.WriteString(sb.String())
 	return err
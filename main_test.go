@@ -0,0 +1,1053 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"word-downloader/anki"
+	"word-downloader/cache"
+	"word-downloader/dict"
+	"word-downloader/dict/bingdict"
+	"word-downloader/dict/dictcn"
+	"word-downloader/dict/webster"
+	"word-downloader/lemma"
+	"word-downloader/media"
+	"word-downloader/wordlist"
+
+	"golang.org/x/time/rate"
+)
+
+func TestParseRates(t *testing.T) {
+	rates, err := parseRates("webster=2/s, dictcn=30/m,collins=360/h")
+	if err != nil {
+		t.Fatalf("cannot parse: %v", err)
+	}
+	want := map[dict.Dictionary]rate.Limit{
+		dict.Webster: 2,
+		dict.Dictcn:  0.5,
+		dict.Collins: 0.1,
+	}
+	for d, limit := range want {
+		if rates[d] != limit {
+			t.Errorf("%v: want %v, got %v", d, limit, rates[d])
+		}
+	}
+
+	for _, spec := range []string{"webster", "webster=2", "webster=x/s", "webster=2/d", "webster=-1/s"} {
+		if _, err := parseRates(spec); err == nil {
+			t.Errorf("%v: want error", spec)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	*retryBackoff = time.Millisecond
+	transient := &dict.Error{Kind: dict.ErrTransient, Err: fmt.Errorf("connection reset")}
+
+	calls := 0
+	err := retry(context.Background(), "word", func() error {
+		calls++
+		if calls < 3 {
+			return transient
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("want success after 3 calls, got %v after %v calls", err, calls)
+	}
+
+	calls = 0
+	err = retry(context.Background(), "word", func() error {
+		calls++
+		return dict.ErrNotFound
+	})
+	if err != dict.ErrNotFound || calls != 1 {
+		t.Fatalf("want no retry of ErrNotFound, got %v after %v calls", err, calls)
+	}
+
+	calls = 0
+	err = retry(context.Background(), "word", func() error {
+		calls++
+		return transient
+	})
+	if err != transient || calls != *retries+1 {
+		t.Fatalf("want %v calls, got %v after %v calls", *retries+1, err, calls)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt := 0; attempt < 100; attempt++ {
+		d := backoff(time.Second, attempt)
+		max := time.Second << uint(attempt)
+		if max > maxBackoff || max <= 0 {
+			max = maxBackoff
+		}
+		if d < max/2 || d > max {
+			t.Fatalf("attempt %v: %v not in [%v, %v]", attempt, d, max/2, max)
+		}
+	}
+}
+
+func TestTakeFailures(t *testing.T) {
+	dir := t.TempDir()
+	f, err := os.Create(filepath.Join(dir, "audio-error.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wordsDb, err := cache.OpenBolt(filepath.Join(dir, "words.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &Downloader{audioErrFile: f, cache: wordsDb}
+	d.recordFailure("regret", "", fmt.Errorf("parse failure"))
+	d.recordFailure("kestrel", "https://example.org/kestrel.mp3", fmt.Errorf("timeout"))
+	d.recordFailure("regret", "https://example.org/regret.mp3", fmt.Errorf("timeout"))
+	d.recordFailure("ignored", "", context.Canceled)
+
+	if err := d.takeFailures(); err != nil {
+		t.Fatal(err)
+	}
+	if words := d.failedWords(); !reflect.DeepEqual(words, []string{"regret", "kestrel"}) {
+		t.Fatalf("unexpected failed words: %v", words)
+	}
+	d.forgetFailures("regret")
+	d.close()
+
+	buf, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(buf)), "\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], `"Word":"kestrel"`) {
+		t.Fatalf("want only the kestrel failure kept, got: %v", lines)
+	}
+}
+
+// fakeDict finds the words in found, counting the lookups.
+type fakeDict struct {
+	found   map[string]bool
+	lookups int
+}
+
+type fakeWord struct {
+	W string
+}
+
+func (w fakeWord) Word() string                        { return w.W }
+func (w fakeWord) Pronunciation() string               { return "" }
+func (w fakeWord) DefinitionHtml(showWord bool) string { return "" }
+func (w fakeWord) Json() string                        { buf, _ := json.Marshal(w); return string(buf) }
+func (w fakeWord) Type() dict.Dictionary               { return "fake" }
+func (w fakeWord) Mp3() []string                       { return nil }
+
+func (f *fakeDict) Lookup(word string) (dict.Word, error) {
+	return f.LookupContext(context.Background(), word)
+}
+
+func (f *fakeDict) LookupContext(ctx context.Context, word string) (dict.Word, error) {
+	f.lookups++
+	if !f.found[word] {
+		return nil, dict.NotFound("no such word")
+	}
+	return fakeWord{W: word}, nil
+}
+
+func (f *fakeDict) Parse(wordJson []byte) (dict.Word, error) {
+	var w fakeWord
+	return w, json.Unmarshal(wordJson, &w)
+}
+
+func (f *fakeDict) Type() dict.Dictionary { return "fake" }
+
+func newTestDownloader(t *testing.T, d dict.Dict) *Downloader {
+	wordsDb, err := cache.OpenBolt(filepath.Join(t.TempDir(), "words.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = wordsDb.Close() })
+	return &Downloader{dict: d, cache: wordsDb, limiter: rate.NewLimiter(rate.Inf, 1), timeout: time.Minute}
+}
+
+func TestDownloader_NotFoundTTL(t *testing.T) {
+	fake := &fakeDict{found: map[string]bool{}}
+	d := newTestDownloader(t, fake)
+	*downloadMp3 = false
+	defer func() { *notFoundTTL = 0 }()
+
+	if _, _, err := d.download(context.Background(), "kestrel"); !errors.Is(err, dict.ErrNotFound) {
+		t.Fatalf("want ErrNotFound, got %v", err)
+	}
+	entry, _, _ := d.cache.Get("kestrel")
+	if !entry.NotFound || entry.Reason != "not found: no such word" {
+		t.Fatalf("unexpected not found entry: %+v", entry)
+	}
+
+	// the dictionary adds the word, but the marker is fresh
+	fake.found["kestrel"] = true
+	*notFoundTTL = time.Hour
+	if _, _, err := d.download(context.Background(), "kestrel"); !errors.Is(err, dict.ErrNotFound) || fake.lookups != 1 {
+		t.Fatalf("want cached ErrNotFound, got %v after %v lookups", err, fake.lookups)
+	}
+
+	entry.FetchedAt = time.Now().Add(-2 * time.Hour)
+	_ = d.cache.Put(entry, "kestrel")
+	word, _, err := d.download(context.Background(), "kestrel")
+	if err != nil || word.Word() != "kestrel" || fake.lookups != 2 {
+		t.Fatalf("want kestrel found again, got %v, %v after %v lookups", word, err, fake.lookups)
+	}
+}
+
+func TestOpenCache_ImportWordsTxt(t *testing.T) {
+	defer func(dir string) { *dataDir = dir }(*dataDir)
+	*dataDir = t.TempDir()
+	fake := &fakeDict{found: map[string]bool{}}
+	if err := os.MkdirAll(dictDir(fake.Type()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	wordsTxt, err := cache.OpenFile(filepath.Join(dictDir(fake.Type()), "words.txt"), fake.Parse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = wordsTxt.Put(cache.Entry{Json: []byte(fakeWord{W: "regret"}.Json())}, "regret")
+	_ = wordsTxt.Close()
+
+	// an upgrade to the bolt cache keeps the looked up words
+	c, err := openCache(fake)
+	if err != nil {
+		t.Fatalf("cannot open: %v", err)
+	}
+	defer c.Close()
+	if _, exist, err := c.Get("regret"); !exist || err != nil {
+		t.Fatalf("want regret imported, got: %v, %v", exist, err)
+	}
+	if _, err := os.Stat(filepath.Join(dictDir(fake.Type()), "words.db.tmp")); !os.IsNotExist(err) {
+		t.Fatalf("want no temporary file, got: %v", err)
+	}
+}
+
+func TestFetchFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/regret.mp3":
+			w.Header().Set("Content-Type", "audio/mpeg")
+			_, _ = w.Write([]byte("ID3\x03 regret"))
+		case "/blocked.mp3":
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte("<html>blocked</html>"))
+		case "/page.mp3":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = w.Write([]byte("<!DOCTYPE html><html>not found</html>"))
+		case "/page.png":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write([]byte("<html>not found</html>"))
+		case "/big.mp3":
+			_, _ = w.Write(append([]byte("ID3"), make([]byte, media.MaxSizes[media.KindAudio])...))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+
+	tmpFile, err := fetchFile(context.Background(), server.URL+"/regret.mp3", media.KindAudio, dir, time.Minute)
+	if err != nil {
+		t.Fatalf("cannot fetch: %v", err)
+	}
+	if buf, _ := os.ReadFile(tmpFile); string(buf) != "ID3\x03 regret" {
+		t.Fatalf("unexpected content: %q", buf)
+	}
+	_ = os.Remove(tmpFile)
+
+	for _, tt := range []struct {
+		path string
+		kind media.Kind
+		err  string
+	}{
+		{"/missing.mp3", media.KindAudio, "http status 404 Not Found"},
+		{"/blocked.mp3", media.KindAudio, "blocked: http status 403 Forbidden"},
+		{"/page.mp3", media.KindAudio, "an html or xml page, not audio"},
+		{"/page.png", media.KindPicture, "content type text/html, not pic"},
+		{"/big.mp3", media.KindAudio, fmt.Sprintf("%v bytes, over the limit of %v", media.MaxSizes[media.KindAudio]+1, media.MaxSizes[media.KindAudio])},
+	} {
+		_, err := fetchFile(context.Background(), server.URL+tt.path, tt.kind, dir, time.Minute)
+		if fmt.Sprint(err) != tt.err {
+			t.Errorf("%v: want error %q, got: %v", tt.path, tt.err, err)
+		}
+	}
+	// no partial download is left behind
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("want no temp file, got: %v", entries)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	config, err := loadConfig("config.example.yaml")
+	if err != nil {
+		t.Fatalf("cannot load: %v", err)
+	}
+	if errs := config.validate(); len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if config.Timeout != time.Minute || config.dictConfig(dict.Webster).Timeout != 30*time.Second {
+		t.Fatalf("unexpected timeouts: %v, %v", config.Timeout, config.dictConfig(dict.Webster).Timeout)
+	}
+	if dc := config.dictConfig(dict.Collins); dc.Backend != "fallback" || dc.Selenium == nil || dc.Selenium.Port != 8080 {
+		t.Fatalf("unexpected collins config: %+v", dc)
+	}
+	if p := config.Preprocess; !p.Dedupe || p.FrequencyList != "word-list/sorted.txt" || p.MinFrequency != 2 {
+		t.Fatalf("unexpected preprocess config: %+v", p)
+	}
+	if a := config.Media.Audio; !reflect.DeepEqual(a.Accents, []string{"us", "uk"}) || a.Clips == nil || *a.Clips != 1 {
+		t.Fatalf("unexpected audio config: %+v", a)
+	}
+	if len(config.Export.NoteTypes) != 4 || config.Export.NoteTypes[2].Kind != kindCloze {
+		t.Fatalf("unexpected note types: %+v", config.Export.NoteTypes)
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad.yaml")
+	if err := os.WriteFile(bad, []byte("dictionary:\n  - name: webster\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(bad); err == nil {
+		t.Fatalf("want error of unknown field")
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	retries := -1
+	clips := 0
+	config := &Config{
+		Cache:   "redis",
+		Retries: &retries,
+		Media:   MediaConfig{Audio: AudioConfig{Voices: []string{"female", "child"}, Clips: &clips}},
+		Dictionaries: []DictConfig{
+			{Name: "webster", BaseUrl: "merriam-webster.com", Rate: "fast"},
+			{Name: "webster"},
+			{Name: "oxford"},
+			{Name: "dictcn", Backend: "selenium"},
+			{Name: "collins", Backend: "chrome", Proxy: "http://127.0.0.1:8888"},
+		},
+	}
+	var got []string
+	for _, err := range config.validate() {
+		got = append(got, err.Error())
+	}
+	want := []string{
+		"cache: unknown cache type 'redis'",
+		"retries: must not be negative",
+		"media: audio: unknown audio voice 'child'",
+		"media: audio: clips: must be at least 1",
+		"dictionaries[0] (webster): base_url: 'merriam-webster.com' is not an absolute url",
+		"dictionaries[0] (webster): rate: missing unit",
+		"dictionaries[1] (webster): duplicated dictionary",
+		"dictionaries[2] (oxford): unsupported dictionary 'oxford'",
+		"dictionaries[3] (dictcn): backend and selenium are for collins only",
+		"dictionaries[4] (collins): unknown backend 'chrome'",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want:\n%v\ngot:\n%v", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	dicts := fs.String("dicts", "webster", "")
+	timeout := fs.Duration("timeout", time.Minute, "")
+	concurrency := fs.Int("concurrency", 1, "")
+	anki := fs.Bool("anki", false, "")
+	if err := fs.Parse([]string{"-timeout", "10s"}); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &Config{
+		Timeout:     time.Hour,
+		Concurrency: 4,
+		Dictionaries: []DictConfig{
+			{Name: "collins", Timeout: time.Hour},
+			{Name: "bing-dict"},
+		},
+		Export: ExportConfig{AnkiCsv: "out.csv"},
+	}
+	if err := applyConfig(fs, config); err != nil {
+		t.Fatalf("cannot apply: %v", err)
+	}
+	if *dicts != "collins,bing-dict" || *concurrency != 4 || !*anki {
+		t.Fatalf("config not applied: %v, %v, %v", *dicts, *concurrency, *anki)
+	}
+	// given on the command line
+	if *timeout != 10*time.Second || config.dictConfig(dict.Collins).Timeout != 0 {
+		t.Fatalf("-timeout is overridden: %v, %v", *timeout, config.dictConfig(dict.Collins).Timeout)
+	}
+}
+
+func TestApkgExporter(t *testing.T) {
+	defer func(dir string) { *dataDir = dir }(*dataDir)
+	*dataDir = t.TempDir()
+	setupMediaStore(t)
+	name, _ := storeMedia(t, "https://media.merriam-webster.com/audio/prons/en/us/mp3/r/regret01.mp3", "mp3")
+
+	e := newApkgExporter(filepath.Join(*dataDir, "words.apkg"), "words")
+	downloaded := webster.Word{W: "regret", Audio: webster.Audio{Mp3: "https://media.merriam-webster.com/audio/prons/en/us/mp3/r/regret01.mp3"}}
+	missing := webster.Word{W: "exhort", Audio: webster.Audio{Mp3: "https://media.merriam-webster.com/audio/prons/en/us/mp3/e/exhort01.mp3"}}
+	for _, words := range [][]dict.Word{{downloaded}, {missing}} {
+		if err := e.add(context.Background(), words); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(e.deck.Media) != 1 || e.deck.Media[name] == "" {
+		t.Fatalf("want only the downloaded audio, got: %v", e.deck.Media)
+	}
+	if sound := e.deck.Notes[0].Fields[4]; sound != "[sound:"+name+"]" {
+		t.Fatalf("unexpected sound of regret: %v", sound)
+	}
+	if sound := e.deck.Notes[1].Fields[4]; sound != "" {
+		t.Fatalf("want no sound of exhort, got: %v", sound)
+	}
+	if err := e.close(); err != nil {
+		t.Fatalf("cannot write apkg: %v", err)
+	}
+}
+
+// setupMediaStore opens the media store of -data-dir for the test.
+func setupMediaStore(t *testing.T) {
+	store := mediaStore
+	t.Cleanup(func() { mediaStore = store })
+	if err := openMediaStore(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// storeMedia adds content as the file of url to the media store, and
+// returns its name and path.
+func storeMedia(t *testing.T, url string, content string) (string, string) {
+	tmpFile := filepath.Join(t.TempDir(), "download.tmp")
+	if err := os.WriteFile(tmpFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	name, err := mediaStore.Add(url, tmpFile, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return name, mediaStore.Path(name)
+}
+
+func TestMediaFile(t *testing.T) {
+	defer func(dir string) { *dataDir = dir }(*dataDir)
+	*dataDir = t.TempDir()
+	setupMediaStore(t)
+	// the same base name in two dictionaries
+	websterName, _ := storeMedia(t, "https://media.merriam-webster.com/audio/prons/en/us/mp3/r/regret01.mp3", "webster mp3")
+	bingName, _ := storeMedia(t, "https://bing.test/us/regret01.mp3", "bing mp3")
+	if websterName == bingName {
+		t.Fatalf("want distinct names, got: %v", websterName)
+	}
+	// the same audio in two dictionaries
+	dictcnName, _ := storeMedia(t, "https://dict.test/regret.mp3", "bing mp3")
+	if dictcnName != bingName {
+		t.Fatalf("want the name of the same audio %v, got: %v", bingName, dictcnName)
+	}
+	if name, _, ok := mediaFile("https://bing.test/us/regret01.mp3"); !ok || name != bingName {
+		t.Fatalf("want %v, got: %v, %v", bingName, name, ok)
+	}
+	if _, _, ok := mediaFile("https://bing.test/uk/regret01.mp3"); ok {
+		t.Fatalf("want no file of a url not downloaded")
+	}
+
+	// the processed audio is exported instead of the original
+	defer func() { *audioProcess = false }()
+	*audioProcess = true
+	processed := mediaStore.VariantPath(media.ProcessedName(bingName), audioProcessing().Variant())
+	if err := os.MkdirAll(filepath.Dir(processed), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(processed, []byte("RIFF"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if name, file, ok := mediaFile("https://bing.test/us/regret01.mp3"); !ok || name != media.ProcessedName(bingName) || file != processed {
+		t.Fatalf("want the processed %v, got: %v, %v, %v", processed, name, file, ok)
+	}
+	// unless it is not processed yet
+	if name, _, ok := mediaFile("https://media.merriam-webster.com/audio/prons/en/us/mp3/r/regret01.mp3"); !ok || name != websterName {
+		t.Fatalf("want the original %v, got: %v, %v", websterName, name, ok)
+	}
+}
+
+func TestAudioPreference(t *testing.T) {
+	defer func(prefs audioPreference, dir string) { audioPrefs, *dataDir = prefs, dir }(audioPrefs, *dataDir)
+	*dataDir = t.TempDir()
+	setupMediaStore(t)
+	if _, err := parseAudioPreference("us,au", "", ""); fmt.Sprint(err) != "unknown audio accent 'au'" {
+		t.Fatalf("want error of unknown accent, got: %v", err)
+	}
+	words := []dict.Word{
+		webster.Word{W: "regret", Audio: webster.Audio{Mp3: "https://webster.test/regret01.mp3"}},
+		dictcn.Word{W: "regret", Audio: dictcn.Audio{
+			Us: struct{ Pronunciation, MaleMp3, FemaleMp3 string }{MaleMp3: "https://dictcn.test/us-male.mp3", FemaleMp3: "https://dictcn.test/us-female.mp3"},
+			Uk: struct{ Pronunciation, MaleMp3, FemaleMp3 string }{MaleMp3: "https://dictcn.test/uk-male.mp3", FemaleMp3: "https://dictcn.test/uk-female.mp3"},
+		}},
+		bingdict.Word{W: "regret", Audio: bingdict.Audio{USAudio: "https://bing.test/us.mp3", UKAudio: "https://bing.test/uk.mp3"}},
+	}
+	names := map[string]string{}
+	for i, url := range []string{
+		"https://webster.test/regret01.mp3",
+		"https://dictcn.test/us-male.mp3", "https://dictcn.test/uk-male.mp3", "https://dictcn.test/uk-female.mp3",
+		"https://bing.test/us.mp3", "https://bing.test/uk.mp3",
+	} {
+		names[url], _ = storeMedia(t, url, fmt.Sprintf("ID3 %v", i))
+	}
+	sounds := func(urls ...string) string {
+		var s string
+		for _, url := range urls {
+			s += "[sound:" + names[url] + "]"
+		}
+		return s
+	}
+
+	for _, tt := range []struct {
+		accents, voices, dicts string
+		field                  FieldConfig
+		want                   string
+	}{
+		// the first dictionary
+		{"", "", "", FieldConfig{Count: 1}, sounds("https://webster.test/regret01.mp3")},
+		// the best of each accent, then the next best
+		{"uk,us", "female", "", FieldConfig{Count: 3}, sounds("https://dictcn.test/uk-female.mp3", "https://webster.test/regret01.mp3", "https://bing.test/uk.mp3")},
+		// the us female audio of dictcn is not downloaded
+		{"us", "female,male", "dictcn,bing-dict", FieldConfig{Count: 2}, sounds("https://dictcn.test/us-male.mp3", "https://bing.test/us.mp3")},
+		{"", "male", "dictcn", FieldConfig{Count: 5, Accent: dict.AccentUk}, sounds("https://dictcn.test/uk-male.mp3")},
+		{"uk", "", "webster", FieldConfig{Count: 1}, ""},
+	} {
+		var err error
+		if audioPrefs, err = parseAudioPreference(tt.accents, tt.voices, tt.dicts); err != nil {
+			t.Fatal(err)
+		}
+		media := map[string]string{}
+		if got := audioValue(tt.field, words, media); got != tt.want {
+			t.Errorf("%v/%v/%v %+v: want %v, got: %v", tt.accents, tt.voices, tt.dicts, tt.field, tt.want, got)
+		}
+		if len(media) != strings.Count(tt.want, "[sound:") {
+			t.Errorf("unexpected media: %v", media)
+		}
+	}
+
+	// the downloads
+	audioPrefs, _ = parseAudioPreference("uk", "female", "")
+	var allowed []string
+	for _, word := range words {
+		for _, audio := range dict.Audios(word) {
+			if audioPrefs.allows(word.Type(), audio) {
+				allowed = append(allowed, audio.Url)
+			}
+		}
+	}
+	if want := []string{"https://dictcn.test/uk-female.mp3", "https://bing.test/uk.mp3"}; !reflect.DeepEqual(allowed, want) {
+		t.Fatalf("want downloads %v, got: %v", want, allowed)
+	}
+}
+
+// recordExporter keeps the head words of the added cards.
+type recordExporter struct {
+	words  []string
+	closed bool
+}
+
+func (e *recordExporter) add(_ context.Context, words []dict.Word) error {
+	e.words = append(e.words, words[0].Word())
+	return nil
+}
+
+func (e *recordExporter) notes(words []dict.Word) []ankiNote {
+	return buildNotes(words)
+}
+
+func (e *recordExporter) close() error {
+	e.closed = true
+	return nil
+}
+
+func TestWriteResults_Order(t *testing.T) {
+	// the workers finish the words out of order, and the job 3 is dropped
+	results := make(chan lookupResult, 5)
+	for _, index := range []int{2, 0, 4, 1, 5} {
+		results <- lookupResult{index: index, words: []dict.Word{fakeWord{W: fmt.Sprint("word", index)}}}
+	}
+	close(results)
+	path := filepath.Join(t.TempDir(), "anki-flashcard.csv")
+	csv, err := newCsvExporter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := &recordExporter{}
+	if count := writeResults(context.Background(), results, []exporter{csv, record}); count != 3 {
+		t.Fatalf("want 3 words written, got: %v", count)
+	}
+	_ = csv.close()
+	if want := []string{"word0", "word1", "word2"}; !reflect.DeepEqual(record.words, want) {
+		t.Fatalf("want %v, got: %v", want, record.words)
+	}
+	buf, _ := os.ReadFile(path)
+	var words []string
+	for _, line := range strings.Split(strings.TrimSpace(string(buf)), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			words = append(words, strings.Split(line, "|")[0])
+		}
+	}
+	if want := []string{"word0", "word1", "word2"}; !reflect.DeepEqual(words, want) {
+		t.Fatalf("want the csv in input order %v, got: %v", want, words)
+	}
+}
+
+// slowDict finds every word, the first ones the slowest.
+type slowDict struct {
+	fakeDict
+	delays map[string]time.Duration
+}
+
+func (f *slowDict) LookupContext(ctx context.Context, word string) (dict.Word, error) {
+	time.Sleep(f.delays[word])
+	return fakeWord{W: word}, nil
+}
+
+func TestRunWorkers_Order(t *testing.T) {
+	*downloadMp3 = false
+	keywords := []string{"regret", "kestrel", "alpine", "exhort", "falcon", "tinnunculus"}
+	slow := &slowDict{delays: map[string]time.Duration{}}
+	for i, keyword := range keywords {
+		slow.delays[keyword] = time.Duration(len(keywords)-i) * 10 * time.Millisecond
+	}
+	d := newTestDownloader(t, slow)
+	jobs := make(chan lookupJob, len(keywords))
+	for i, keyword := range keywords {
+		jobs <- lookupJob{index: i, keyword: keyword, original: keyword}
+	}
+	close(jobs)
+
+	record := &recordExporter{}
+	count := writeResults(context.Background(), runWorkers(context.Background(), 4, []*Downloader{d}, jobs), []exporter{record})
+	if count != len(keywords) || !reflect.DeepEqual(record.words, keywords) {
+		t.Fatalf("want %v in input order, got %v words: %v", keywords, count, record.words)
+	}
+	for _, keyword := range keywords {
+		if _, exist, err := d.cache.Get(keyword); !exist || err != nil {
+			t.Fatalf("want %v cached, got: %v, %v", keyword, exist, err)
+		}
+	}
+}
+
+func TestManifestExporter_Incremental(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export-manifest.json")
+	export := func(words ...dict.Word) []string {
+		manifest, err := loadManifest(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		next := &recordExporter{}
+		e := newManifestExporter("csv:anki-flashcard.csv", next, manifest, true)
+		for _, w := range words {
+			if err := e.add(context.Background(), []dict.Word{w}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := e.close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := manifest.save(); err != nil {
+			t.Fatal(err)
+		}
+		return next.words
+	}
+
+	regret := webster.Word{W: "regret"}
+	exhort := webster.Word{W: "exhort"}
+	if got := export(regret, exhort); !reflect.DeepEqual(got, []string{"regret", "exhort"}) {
+		t.Fatalf("want all cards at first, got: %v", got)
+	}
+	if got := export(regret, exhort); len(got) != 0 {
+		t.Fatalf("want no unchanged card, got: %v", got)
+	}
+	changed := webster.Word{W: "exhort", Audio: webster.Audio{Syllables: "ex·hort"}}
+	kestrel := webster.Word{W: "kestrel"}
+	if got := export(regret, changed, kestrel); !reflect.DeepEqual(got, []string{"exhort", "kestrel"}) {
+		t.Fatalf("want changed and new cards, got: %v", got)
+	}
+}
+
+func TestManifestExporter_Csv(t *testing.T) {
+	defer func(types []*noteType) { noteTypes = types }(noteTypes)
+	if err := setupNoteTypes(nil, true); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	manifest, err := loadManifest(filepath.Join(dir, "export-manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	csv, err := newCsvExporter(filepath.Join(dir, "anki-flashcard.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := newManifestExporter("csv:anki-flashcard.csv", csv, manifest, true)
+	regret := webster.Word{W: "regret", Defs: []webster.Definition{{PartOfSpeech: "verb", DefinitionEntry: []webster.DefinitionEntry{{
+		SubDefinitionEntry: []webster.SubDefinition{{Def: " : to be very sorry for", Examples: []webster.Example{{Text: "I regret that I cannot come"}}}},
+	}}}}}
+	if len(buildNotes([]dict.Word{regret})) != 2 {
+		t.Fatalf("want a recognition and a cloze note")
+	}
+	if err := e.add(context.Background(), []dict.Word{regret}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.close(); err != nil {
+		t.Fatal(err)
+	}
+	// the cloze note is not written to the csv
+	records := manifest.Targets["csv:anki-flashcard.csv"]
+	if _, ok := records[anki.Guid("word-downloader", "regret")]; !ok || len(records) != 1 {
+		t.Fatalf("want only the recognition note recorded, got: %v", records)
+	}
+}
+
+func TestAnkiConnectExporter_Cancel(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte(`{"result": [], "error": null}`))
+	}))
+	defer server.Close()
+
+	e := &ankiConnectExporter{client: anki.NewClient(server.URL, nil), deck: "words", stored: map[string]bool{}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := e.add(ctx, []dict.Word{fakeWord{W: "regret"}}); err == nil || !strings.Contains(err.Error(), "context canceled") {
+		t.Fatalf("want the sync canceled, got: %v", err)
+	}
+	if requests != 0 {
+		t.Fatalf("want no request, got: %v", requests)
+	}
+}
+
+func TestWriteToAnkiCsv_Guid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "anki-flashcard.csv")
+	e, err := newCsvExporter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.add(context.Background(), []dict.Word{fakeWord{W: "regret"}}); err != nil {
+		t.Fatal(err)
+	}
+	_ = e.close()
+	buf, _ := os.ReadFile(path)
+	lines := strings.Split(strings.TrimSpace(string(buf)), "\n")
+	if lines[2] != "#notetype:word-downloader" || lines[3] != "#guid column:7" {
+		t.Fatalf("unexpected headers: %v", lines[:4])
+	}
+	fields := strings.Split(lines[4], "|")
+	if len(fields) != 7 || fields[0] != "regret" || fields[6] != anki.Guid("word-downloader", "regret") {
+		t.Fatalf("unexpected row: %v", lines[4])
+	}
+}
+
+func TestNewNoteType(t *testing.T) {
+	for _, tt := range []struct {
+		config NoteTypeConfig
+		err    string
+	}{
+		{NoteTypeConfig{Name: "recall", Kind: kindRecall}, ""},
+		{NoteTypeConfig{Name: "cloze", Kind: kindCloze}, ""},
+		{NoteTypeConfig{Name: "vocab", Kind: "spelling"}, "unknown kind 'spelling'"},
+		{NoteTypeConfig{Kind: kindRecall}, "missing name"},
+		{NoteTypeConfig{Name: "vocab", Fields: []FieldConfig{
+			{Name: "Word", Source: sourceWord},
+			{Name: "Sound", Source: "video"},
+		}}, "fields[1] (Sound): unknown source 'video'"},
+		{NoteTypeConfig{Name: "vocab", Fields: []FieldConfig{
+			{Name: "Word", Source: sourceWord},
+			{Name: "Word", Source: sourceExample},
+		}}, "fields[1]: duplicated field 'Word'"},
+		{NoteTypeConfig{Name: "vocab", Fields: []FieldConfig{
+			{Name: "Word", Source: sourceWord},
+			{Name: "Sound", Source: sourceAudio, Dict: "oxford"},
+		}}, "fields[1] (Sound): unsupported dictionary 'oxford'"},
+		{NoteTypeConfig{Name: "vocab", Fields: []FieldConfig{
+			{Name: "Word", Source: sourceWord},
+			{Name: "Example", Source: sourceExample, Accent: "uk"},
+		}}, "fields[1] (Example): accent is for audio only"},
+		{NoteTypeConfig{Name: "vocab", Fields: []FieldConfig{
+			{Name: "Sound", Source: sourceAudio},
+			{Name: "Word", Source: sourceWord},
+		}}, "fields[0]: the first field must be the word"},
+		{NoteTypeConfig{Name: "vocab", Fields: []FieldConfig{
+			{Name: "Word", Source: sourceWord},
+		}}, "the template references the missing field 'Sound'"},
+		{NoteTypeConfig{Name: "vocab", Front: "{{Word}}", Back: "{{FrontSide}}{{#Tags}}{{Tags}}{{/Tags}}", Fields: []FieldConfig{
+			{Name: "Word", Source: sourceWord},
+		}}, ""},
+		{NoteTypeConfig{Name: "vocab", Kind: kindCloze, Front: "{{Text}}"}, "the front of a cloze must have a {{cloze:Field}}"},
+		{NoteTypeConfig{Name: "vocab", Fields: []FieldConfig{
+			{Name: "Word", Source: sourceWord, Count: 2},
+		}}, "fields[0] (Word): count is for example, cloze and audio only"},
+	} {
+		_, err := newNoteType(tt.config)
+		if got := fmt.Sprint(err); tt.err == "" && err != nil || tt.err != "" && got != tt.err {
+			t.Errorf("%+v: want error %q, got: %v", tt.config, tt.err, err)
+		}
+	}
+}
+
+func TestNoteType_Note(t *testing.T) {
+	defer func(dir string) { *dataDir = dir }(*dataDir)
+	*dataDir = t.TempDir()
+	regret := []dict.Word{
+		webster.Word{
+			W:     "regret",
+			Audio: webster.Audio{Mp3: "https://media.merriam-webster.com/audio/prons/en/us/mp3/r/regret01.mp3"},
+			Defs: []webster.Definition{{PartOfSpeech: "verb", DefinitionEntry: []webster.DefinitionEntry{{
+				SubDefinitionEntry: []webster.SubDefinition{{Def: " : to be very sorry for", Examples: []webster.Example{{Text: "Regrets his mistakes"}, {Text: "I regret that I cannot come"}}}},
+			}}}},
+		},
+		dictcn.Word{
+			W:        "regret",
+			BasicDef: []dictcn.BasicDefinition{{ParOfSpeech: "v.", Def: "后悔；懊悔"}, {ParOfSpeech: "n.", Def: "遗憾"}},
+		},
+		bingdict.Word{
+			W:        "regret",
+			Audio:    bingdict.Audio{USAudio: "https://bing.test/us/regret_us.mp3", UKAudio: "https://bing.test/uk/regret_uk.mp3"},
+			Pictures: []string{"https://bing.test/th?id=regret&w=80", "https://bing.test/pic/regret.png"},
+		},
+	}
+	setupMediaStore(t)
+	// the uk audio of bing is downloaded, the webster one is not
+	ukName, ukFile := storeMedia(t, "https://bing.test/uk/regret_uk.mp3", "mp3")
+	// the thumbnail of the second picture is made, the first one is not downloaded
+	pngName, _ := storeMedia(t, "https://bing.test/pic/regret.png", "png")
+	thumbnail := mediaStore.VariantPath(pngName, thumbDir(*picMaxSize))
+	if err := os.MkdirAll(filepath.Dir(thumbnail), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(thumbnail, []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	recognition := mustNoteType(NoteTypeConfig{
+		Name: "vocab",
+		Fields: []FieldConfig{
+			{Name: "Word", Source: sourceWord},
+			{Name: "Chinese", Source: sourceBasicDefinition, Dict: "dictcn"},
+			{Name: "Example", Source: sourceExample, Dict: "webster"},
+			{Name: "Sound", Source: sourceAudio, Accent: dict.AccentUk},
+			{Name: "Us", Source: sourceAudio, Accent: dict.AccentUs},
+			{Name: "Cloze", Source: sourceCloze, Count: 2},
+			{Name: "Picture", Source: sourcePicture},
+		},
+		Front: "{{Word}}",
+		Back:  "{{Chinese}}{{Example}}{{Sound}}{{Us}}{{Cloze}}{{Picture}}",
+	})
+	note, ok := recognition.note(regret)
+	if !ok {
+		t.Fatalf("want a note")
+	}
+	want := []string{
+		"regret",
+		"v. 后悔；懊悔<br>n. 遗憾",
+		"I regret that I cannot come",
+		"[sound:" + ukName + "]",
+		"",
+		"I {{c1::regret}} that I cannot come<br>{{c2::Regrets}} his mistakes",
+		`<img class="picture" src="` + pngName + `">`,
+	}
+	if !reflect.DeepEqual(note.Fields, want) {
+		t.Fatalf("want fields:\n%q\ngot:\n%q", want, note.Fields)
+	}
+	if len(note.media) != 2 || note.media[ukName] != ukFile || note.media[pngName] != thumbnail {
+		t.Fatalf("unexpected media: %v", note.media)
+	}
+	if note.Guid != anki.Guid("vocab", "regret") {
+		t.Fatalf("unexpected guid: %v", note.Guid)
+	}
+
+	// the front of listening is empty without the us audio
+	listening := mustNoteType(NoteTypeConfig{Name: "listening", Kind: kindListening, Fields: []FieldConfig{
+		{Name: "Word", Source: sourceWord},
+		{Name: "Pronunciation", Source: sourcePronunciation},
+		{Name: "Definition", Source: sourceDefinition},
+		{Name: "Sound", Source: sourceAudio, Accent: dict.AccentUs},
+	}})
+	if _, ok := listening.note(regret); ok {
+		t.Fatalf("want no listening note without audio")
+	}
+	// the picture of the default template is left out with the configured fields
+	if strings.Contains(listening.template.Back, "Picture") {
+		t.Fatalf("unexpected picture in template: %v", listening.template.Back)
+	}
+	cloze := mustNoteType(NoteTypeConfig{Name: "cloze", Kind: kindCloze})
+	if _, ok := cloze.note([]dict.Word{webster.Word{W: "exhort"}}); ok {
+		t.Fatalf("want no cloze note without example")
+	}
+}
+
+func TestWriteTemplates(t *testing.T) {
+	var words []dict.Word
+	for dictionary, golden := range map[dict.Dictionary]string{
+		dict.Webster:  "dict/webster/testdata/regret.golden.json",
+		dict.Dictcn:   "dict/dictcn/testdata/regret.golden.json",
+		dict.BingDict: "dict/bingdict/testdata/kestrel.golden.json",
+		dict.Collins:  "dict/collins/testdata/regret.golden.json",
+	} {
+		provider, _ := dict.GetProvider(dictionary)
+		d, err := provider.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		word, err := d.Parse(buf)
+		if err != nil {
+			t.Fatalf("cannot parse %v: %v", golden, err)
+		}
+		words = append(words, word)
+	}
+	render := func() []string {
+		var fields []string
+		for _, note := range buildNotes(words) {
+			fields = append(fields, note.Fields...)
+		}
+		return fields
+	}
+	want := render()
+	if len(want) == 0 || !strings.Contains(strings.Join(want, ""), "word-content") {
+		t.Fatalf("want the definitions rendered, got: %q", want)
+	}
+
+	// the default templates written unchanged render the same
+	dir := t.TempDir()
+	writeTemplates(dir)
+	defer func(css string) { cardCss = css }(cardCss)
+	if err := loadTemplates(dir); err != nil {
+		t.Fatalf("cannot load: %v", err)
+	}
+	defer dict.LoadTemplates("")
+	if got := render(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
+
+func TestSetupNoteTypes(t *testing.T) {
+	defer func(types []*noteType) { noteTypes = types }(noteTypes)
+	if err := setupNoteTypes(nil, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(noteTypes) != 2 || noteTypes[0].Name != "word-downloader" || noteTypes[1].Kind != kindCloze {
+		t.Fatalf("want the default and cloze note types, got: %v", noteTypes)
+	}
+	// a configured cloze is not added twice
+	if err := setupNoteTypes([]NoteTypeConfig{{Name: "cloze", Kind: kindCloze}}, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(noteTypes) != 1 || noteTypes[0].Name != "cloze" {
+		t.Fatalf("want the configured cloze only, got: %v", noteTypes)
+	}
+}
+
+func TestLemmaJobs(t *testing.T) {
+	defer func(l *lemma.Lemmatizer) { lemmatizer = l }(lemmatizer)
+	lemmatizer = lemma.New(func(word string) bool { return word == "run" })
+
+	in := make(chan lookupJob)
+	go func() {
+		defer close(in)
+		for i, keyword := range []string{"running", "ran", "kestrels", "run", "kestrels"} {
+			in <- lookupJob{index: i, keyword: keyword}
+		}
+	}()
+	var got []lookupJob
+	for job := range lemmaJobs(in, nil) {
+		got = append(got, job)
+	}
+	want := []lookupJob{
+		{index: 0, keyword: "running", original: "running"},
+		{index: 1, keyword: "run", original: "ran"},
+		{index: 2, keyword: "kestrels", original: "kestrels"},
+		{index: 3, keyword: "kestrels", original: "kestrels"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want jobs %+v, got %+v", want, got)
+	}
+
+	// the head word answered by the dictionary is recorded
+	recordLemma(lookupJob{keyword: "kestrels", original: "Kestrels"}, []dict.Word{fakeWord{W: "kestrel"}})
+	if l := lemmatizer.Lemma("kestrels"); l != "kestrel" {
+		t.Fatalf("want the lemma kestrel, got: %v", l)
+	}
+	// unless it is not a lemma of the word
+	recordLemma(lookupJob{keyword: "evening", original: "evening"}, []dict.Word{fakeWord{W: "dusk"}})
+	if l := lemmatizer.Lemma("evening"); l != "evening" {
+		t.Fatalf("want no lemma of evening, got: %v", l)
+	}
+}
+
+func TestStatuses(t *testing.T) {
+	defer func(dir string, s *wordlist.Statuses) { *dataDir, wordStatuses = dir, s }(*dataDir, wordStatuses)
+	*dataDir = t.TempDir()
+	var err error
+	if wordStatuses, err = wordlist.LoadStatuses(statusesPath()); err != nil {
+		t.Fatal(err)
+	}
+	wordStatuses.Set("run", wordlist.StatusKnown)
+	wordStatuses.Set("kestrel", wordlist.StatusLearning)
+	skip, err := statusFilter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter := wordlist.Filter{Exclude: skip}
+	if got, want := filter.Apply([]string{"Run", "kestrel", "regret"}), []string{"kestrel", "regret"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+
+	// the lemma of a word is skipped as well
+	defer func(l *lemma.Lemmatizer) { lemmatizer = l }(lemmatizer)
+	lemmatizer = lemma.New(func(word string) bool { return word == "run" })
+	in := make(chan lookupJob, 2)
+	in <- lookupJob{index: 0, keyword: "ran"}
+	in <- lookupJob{index: 1, keyword: "regret"}
+	close(in)
+	var got []string
+	for job := range lemmaJobs(in, skip) {
+		got = append(got, job.keyword)
+	}
+	if want := []string{"regret"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+
+	// a found word is new, unless it has a status
+	recordStatus(lookupJob{keyword: "regret"}, []dict.Word{fakeWord{W: "regret"}})
+	recordStatus(lookupJob{keyword: "kestrel"}, []dict.Word{fakeWord{W: "kestrel"}})
+	recordStatus(lookupJob{keyword: "zzz"}, nil)
+	if got := wordStatuses.Words(""); !reflect.DeepEqual(got, []string{"kestrel", "regret", "run"}) {
+		t.Fatalf("unexpected words: %q", got)
+	}
+	if wordStatuses.Get("regret") != wordlist.StatusNew || wordStatuses.Get("kestrel") != wordlist.StatusLearning {
+		t.Fatalf("unexpected statuses: %v, %v", wordStatuses.Get("regret"), wordStatuses.Get("kestrel"))
+	}
+
+	var out bytes.Buffer
+	markStatuses([]string{"regret", "kestrel", "alpine"}, wordStatuses, strings.NewReader("x\nk\ni\nq\n"), &out)
+	if wordStatuses.Get("regret") != wordlist.StatusKnown || wordStatuses.Get("kestrel") != wordlist.StatusIgnored || wordStatuses.Get("alpine") != "" {
+		t.Fatalf("unexpected marked statuses: %v", wordStatuses.Words(""))
+	}
+	if !strings.HasPrefix(out.String(), "[1/3] regret: [k]nown") {
+		t.Fatalf("unexpected prompt: %v", out.String())
+	}
+}
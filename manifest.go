@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"word-downloader/dict"
+)
+
+// exportManifest records the cards exported to each target, by guid.
+type exportManifest struct {
+	path    string
+	Targets map[string]map[string]exportRecord `json:"targets"`
+}
+
+type exportRecord struct {
+	Word string `json:"word"`
+	// Hash of the note fields
+	Hash       string    `json:"hash"`
+	ExportedAt time.Time `json:"exportedAt"`
+}
+
+func loadManifest(path string) (*exportManifest, error) {
+	manifest := &exportManifest{path: path, Targets: map[string]map[string]exportRecord{}}
+	buf, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return manifest, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buf, manifest); err != nil {
+		return nil, fmt.Errorf("cannot parse %v: %v", path, err)
+	}
+	if manifest.Targets == nil {
+		manifest.Targets = map[string]map[string]exportRecord{}
+	}
+	return manifest, nil
+}
+
+// unchanged tells if the card of guid was exported to target with the same hash.
+func (m *exportManifest) unchanged(target string, guid string, hash string) bool {
+	record, ok := m.Targets[target][guid]
+	return ok && record.Hash == hash
+}
+
+func (m *exportManifest) record(target string, guid string, record exportRecord) {
+	if m.Targets[target] == nil {
+		m.Targets[target] = map[string]exportRecord{}
+	}
+	m.Targets[target][guid] = record
+}
+
+// save writes the manifest through a temp file, never leaving a partial one.
+func (m *exportManifest) save() error {
+	buf, err := json.MarshalIndent(m, "", " ")
+	if err != nil {
+		return err
+	}
+	tmpFile := m.path + ".tmp"
+	if err := os.MkdirAll(filepath.Dir(m.path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(tmpFile, buf, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, m.path)
+}
+
+// noteHash is the content hash of the note fields.
+func noteHash(fields []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(fields, "\x1f")))
+	return hex.EncodeToString(sum[:])
+}
+
+// manifestExporter records the notes exported by next in the manifest
+// once next is closed successfully. In incremental mode, only the words
+// with a note which is new or changed since the last export are passed
+// to next.
+type manifestExporter struct {
+	target      string
+	next        exporter
+	manifest    *exportManifest
+	incremental bool
+	pending     map[string]exportRecord
+	skipped     int
+}
+
+func newManifestExporter(target string, next exporter, manifest *exportManifest, incremental bool) *manifestExporter {
+	return &manifestExporter{
+		target:      target,
+		next:        next,
+		manifest:    manifest,
+		incremental: incremental,
+		pending:     map[string]exportRecord{},
+	}
+}
+
+func (e *manifestExporter) notes(words []dict.Word) []ankiNote {
+	return e.next.notes(words)
+}
+
+// add passes words to next, and records the notes next writes, e.g. only
+// those of the first note type for the csv.
+func (e *manifestExporter) add(ctx context.Context, words []dict.Word) error {
+	notes := e.next.notes(words)
+	hashes := map[string]string{}
+	unchanged := true
+	for _, note := range notes {
+		hashes[note.Guid] = noteHash(note.Fields)
+		unchanged = unchanged && e.manifest.unchanged(e.target, note.Guid, hashes[note.Guid])
+	}
+	if len(notes) == 0 {
+		return nil
+	}
+	if e.incremental && unchanged {
+		e.skipped++
+		return nil
+	}
+	if err := e.next.add(ctx, words); err != nil {
+		return err
+	}
+	for guid, hash := range hashes {
+		e.pending[guid] = exportRecord{Word: words[0].Word(), Hash: hash, ExportedAt: time.Now()}
+	}
+	return nil
+}
+
+func (e *manifestExporter) close() error {
+	if err := e.next.close(); err != nil {
+		return err
+	}
+	for guid, record := range e.pending {
+		e.manifest.record(e.target, guid, record)
+	}
+	if e.incremental {
+		log.Printf("%v: %v notes exported, %v words unchanged", e.target, len(e.pending), e.skipped)
+	}
+	return nil
+}
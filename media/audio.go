@@ -0,0 +1,296 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hajimehoshi/go-mp3"
+)
+
+// PCM is a mono audio, its samples in [-1, 1].
+type PCM struct {
+	SampleRate int
+	Samples    []float64
+}
+
+// Duration is the length of the audio in seconds.
+func (p *PCM) Duration() float64 {
+	return float64(len(p.Samples)) / float64(p.SampleRate)
+}
+
+// DecodeMp3 decodes an mp3 to mono.
+func DecodeMp3(r io.Reader) (*PCM, error) {
+	decoder, err := mp3.NewDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+	// the decoder writes 16 bits little endian stereo samples
+	buf, err := io.ReadAll(decoder)
+	if err != nil && len(buf) == 0 {
+		return nil, err
+	}
+	pcm := &PCM{SampleRate: decoder.SampleRate(), Samples: make([]float64, len(buf)/4)}
+	for i := range pcm.Samples {
+		left := int16(binary.LittleEndian.Uint16(buf[i*4:]))
+		right := int16(binary.LittleEndian.Uint16(buf[i*4+2:]))
+		pcm.Samples[i] = (float64(left) + float64(right)) / 2 / 32768
+	}
+	return pcm, nil
+}
+
+// DecodeWav decodes a 16 bits pcm wav to mono.
+func DecodeWav(r io.Reader) (*PCM, error) {
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !isRiff(buf, "WAVE") {
+		return nil, errors.New("not a wav file")
+	}
+	var channels, bits int
+	pcm := &PCM{}
+	for chunk := buf[12:]; len(chunk) >= 8; {
+		id, size := string(chunk[:4]), int(binary.LittleEndian.Uint32(chunk[4:8]))
+		body := chunk[8:]
+		if size > len(body) {
+			size = len(body)
+		}
+		switch id {
+		case "fmt ":
+			if size < 16 || binary.LittleEndian.Uint16(body) != 1 {
+				return nil, errors.New("not a pcm wav file")
+			}
+			channels = int(binary.LittleEndian.Uint16(body[2:]))
+			pcm.SampleRate = int(binary.LittleEndian.Uint32(body[4:]))
+			bits = int(binary.LittleEndian.Uint16(body[14:]))
+		case "data":
+			if channels == 0 || bits != 16 {
+				return nil, fmt.Errorf("unsupported wav of %v channels, %v bits", channels, bits)
+			}
+			frames := size / 2 / channels
+			pcm.Samples = make([]float64, frames)
+			for i := range pcm.Samples {
+				var sum float64
+				for c := 0; c < channels; c++ {
+					sum += float64(int16(binary.LittleEndian.Uint16(body[(i*channels+c)*2:])))
+				}
+				pcm.Samples[i] = sum / float64(channels) / 32768
+			}
+			return pcm, nil
+		}
+		// the chunks are padded to an even size
+		chunk = body[size+size%2:]
+	}
+	return nil, errors.New("no data in the wav file")
+}
+
+// WriteWav writes the audio as a 16 bits mono pcm wav.
+func WriteWav(w io.Writer, pcm *PCM) error {
+	var buf bytes.Buffer
+	dataSize := len(pcm.Samples) * 2
+	buf.WriteString("RIFF")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(36+dataSize))
+	buf.WriteString("WAVEfmt ")
+	for _, v := range []interface{}{
+		uint32(16),                 // size of fmt
+		uint16(1),                  // pcm
+		uint16(1),                  // channels
+		uint32(pcm.SampleRate),     // sample rate
+		uint32(pcm.SampleRate * 2), // bytes per second
+		uint16(2),                  // bytes per frame
+		uint16(16),                 // bits per sample
+	} {
+		_ = binary.Write(&buf, binary.LittleEndian, v)
+	}
+	buf.WriteString("data")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(dataSize))
+	for _, s := range pcm.Samples {
+		_ = binary.Write(&buf, binary.LittleEndian, int16(math.Round(clamp(s)*32767)))
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func clamp(s float64) float64 {
+	return math.Max(-1, math.Min(1, s))
+}
+
+// dBFS returns the level of an amplitude, in decibels relative to the full
+// scale.
+func dBFS(amplitude float64) float64 {
+	if amplitude <= 0 {
+		return math.Inf(-1)
+	}
+	return 20 * math.Log10(amplitude)
+}
+
+// windowSeconds is the length of the windows of the levels of the audio.
+const windowSeconds = 0.01
+
+// levels returns the rms of each window of 10ms of the audio.
+func levels(pcm *PCM) []float64 {
+	size := int(float64(pcm.SampleRate) * windowSeconds)
+	if size < 1 {
+		size = 1
+	}
+	var rms []float64
+	for start := 0; start < len(pcm.Samples); start += size {
+		end := start + size
+		if end > len(pcm.Samples) {
+			end = len(pcm.Samples)
+		}
+		var sum float64
+		for _, s := range pcm.Samples[start:end] {
+			sum += s * s
+		}
+		rms = append(rms, math.Sqrt(sum/float64(end-start)))
+	}
+	return rms
+}
+
+// TrimSilence removes the leading and trailing silence of the audio, the
+// windows under threshold dBFS, but padding seconds around the sound. An
+// audio which is all silence is left as it is.
+func TrimSilence(pcm *PCM, threshold float64, padding float64) {
+	rms := levels(pcm)
+	first, last := -1, -1
+	for i, level := range rms {
+		if dBFS(level) >= threshold {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if first < 0 {
+		return
+	}
+	size := int(float64(pcm.SampleRate) * windowSeconds)
+	pad := int(float64(pcm.SampleRate) * padding)
+	start := first*size - pad
+	if start < 0 {
+		start = 0
+	}
+	end := (last+1)*size + pad
+	if end > len(pcm.Samples) {
+		end = len(pcm.Samples)
+	}
+	pcm.Samples = pcm.Samples[start:end]
+}
+
+// Loudness returns the loudness of the audio in dBFS, the rms of its
+// windows over threshold dBFS, so the pauses do not lower it.
+func Loudness(pcm *PCM, threshold float64) float64 {
+	var sum float64
+	n := 0
+	for _, level := range levels(pcm) {
+		if dBFS(level) >= threshold {
+			sum += level * level
+			n++
+		}
+	}
+	if n == 0 {
+		return math.Inf(-1)
+	}
+	return dBFS(math.Sqrt(sum / float64(n)))
+}
+
+// maxPeak is the highest peak of a normalized audio in dBFS, the gain is
+// lowered rather than clipping the audio.
+const maxPeak = -1.0
+
+// Normalize scales the audio to the loudness target dBFS, see Loudness,
+// and returns the gain in dB.
+func Normalize(pcm *PCM, target float64, threshold float64) float64 {
+	loudness := Loudness(pcm, threshold)
+	if math.IsInf(loudness, -1) {
+		return 0
+	}
+	gain := target - loudness
+	var peak float64
+	for _, s := range pcm.Samples {
+		peak = math.Max(peak, math.Abs(s))
+	}
+	if dBFS(peak)+gain > maxPeak {
+		gain = maxPeak - dBFS(peak)
+	}
+	scale := math.Pow(10, gain/20)
+	for i := range pcm.Samples {
+		pcm.Samples[i] *= scale
+	}
+	return gain
+}
+
+// AudioProcess is the post processing of the audio.
+type AudioProcess struct {
+	// Loudness is the target of Normalize in dBFS
+	Loudness float64
+	// Silence is the level of the silence in dBFS, see TrimSilence
+	Silence float64
+	// Padding is the silence kept around the sound in seconds
+	Padding float64
+}
+
+// Variant is the variant of the processed audio in a store, e.g.
+// processed-20-50-50ms, a change of any of the settings makes new files.
+func (p AudioProcess) Variant() string {
+	return fmt.Sprintf("processed%+g%+g-%gms", p.Loudness, p.Silence, math.Round(p.Padding*1000))
+}
+
+// ProcessedName returns the name of the processed audio of the file name,
+// a wav.
+func ProcessedName(name string) string {
+	return strings.TrimSuffix(name, filepath.Ext(name)) + ".wav"
+}
+
+// Process trims the silence of the mp3 or wav src and normalizes its
+// loudness, and writes it to dst as a wav.
+func (p AudioProcess) Process(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	var pcm *PCM
+	switch ext := strings.ToLower(filepath.Ext(src)); ext {
+	case ".mp3":
+		pcm, err = DecodeMp3(in)
+	case ".wav":
+		pcm, err = DecodeWav(in)
+	default:
+		return fmt.Errorf("cannot decode %v audio", ext)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot decode %v: %v", src, err)
+	}
+	if len(pcm.Samples) == 0 {
+		return fmt.Errorf("no audio in %v", src)
+	}
+	TrimSilence(pcm, p.Silence, p.Padding)
+	Normalize(pcm, p.Loudness, p.Silence)
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmpFile := dst + ".tmp"
+	out, err := os.Create(tmpFile)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile)
+	err = WriteWav(out, pcm)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, dst)
+}
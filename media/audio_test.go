@@ -0,0 +1,120 @@
+package media
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// tone returns silence seconds of silence, then sound seconds of a sine of
+// amplitude, then silence again.
+func tone(silence, sound, amplitude float64) *PCM {
+	pcm := &PCM{SampleRate: 8000}
+	for i := 0; i < int(silence*8000); i++ {
+		pcm.Samples = append(pcm.Samples, 0)
+	}
+	for i := 0; i < int(sound*8000); i++ {
+		pcm.Samples = append(pcm.Samples, amplitude*math.Sin(2*math.Pi*440*float64(i)/8000))
+	}
+	for i := 0; i < int(silence*8000); i++ {
+		pcm.Samples = append(pcm.Samples, 0)
+	}
+	return pcm
+}
+
+func TestDecodeMp3(t *testing.T) {
+	// frames of mpeg 1 layer 3, 128kbps, 44100Hz, without audio data
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xff, 0xfb, 0x90, 0x64})
+	pcm, err := DecodeMp3(bytes.NewReader(bytes.Repeat(frame, 10)))
+	if err != nil {
+		t.Fatalf("cannot decode: %v", err)
+	}
+	if pcm.SampleRate != 44100 || len(pcm.Samples) == 0 || len(pcm.Samples)%1152 != 0 {
+		t.Fatalf("unexpected audio of %vHz, %v samples", pcm.SampleRate, len(pcm.Samples))
+	}
+	for _, s := range pcm.Samples {
+		if s != 0 {
+			t.Fatalf("want silence, got: %v", s)
+		}
+	}
+}
+
+func TestTrimSilence(t *testing.T) {
+	pcm := tone(0.5, 0.5, 0.1)
+	TrimSilence(pcm, -50, 0.05)
+	if d := pcm.Duration(); d < 0.58 || d > 0.62 {
+		t.Fatalf("want 0.6s, got: %vs", d)
+	}
+	silence := &PCM{SampleRate: 8000, Samples: make([]float64, 800)}
+	TrimSilence(silence, -50, 0.05)
+	if len(silence.Samples) != 800 {
+		t.Fatalf("want the silence kept, got %v samples", len(silence.Samples))
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	pcm := tone(0.5, 0.5, 0.01)
+	if loudness := Loudness(pcm, -50); math.Abs(loudness-dBFS(0.01/math.Sqrt2)) > 0.1 {
+		t.Fatalf("want the loudness of the sine, not of the silence, got: %v", loudness)
+	}
+	Normalize(pcm, -20, -50)
+	if loudness := Loudness(pcm, -50); math.Abs(loudness+20) > 0.1 {
+		t.Fatalf("want -20dBFS, got: %v", loudness)
+	}
+
+	// the gain is lowered rather than clipping
+	loud := tone(0, 0.5, 0.5)
+	if gain := Normalize(loud, -3, -50); math.Abs(gain-(maxPeak-dBFS(0.5))) > 0.01 {
+		t.Fatalf("want a gain up to the max peak, got: %v", gain)
+	}
+}
+
+func TestAudioProcess(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "regret.wav")
+	var buf bytes.Buffer
+	if err := WriteWav(&buf, tone(0.3, 0.5, 0.02)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(src, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateFile(src); err != nil {
+		t.Fatalf("want a valid wav: %v", err)
+	}
+
+	process := AudioProcess{Loudness: -20, Silence: -50, Padding: 0.05}
+	dst := filepath.Join(dir, process.Variant(), ProcessedName("regret.mp3"))
+	if err := process.Process(src, dst); err != nil {
+		t.Fatalf("cannot process: %v", err)
+	}
+	if filepath.Base(filepath.Dir(dst)) != "processed-20-50-50ms" || filepath.Base(dst) != "regret.wav" {
+		t.Fatalf("unexpected processed file: %v", dst)
+	}
+	f, err := os.Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	pcm, err := DecodeWav(f)
+	if err != nil {
+		t.Fatalf("cannot decode: %v", err)
+	}
+	if d := pcm.Duration(); d < 0.58 || d > 0.62 {
+		t.Fatalf("want the silence trimmed, got: %vs", d)
+	}
+	if loudness := Loudness(pcm, -50); math.Abs(loudness+20) > 0.1 {
+		t.Fatalf("want -20dBFS, got: %v", loudness)
+	}
+
+	if padded := (AudioProcess{Loudness: -20, Silence: -50, Padding: 0.1}); padded.Variant() == process.Variant() {
+		t.Fatalf("want another variant of another padding, got: %v", padded.Variant())
+	}
+
+	if err := process.Process(filepath.Join(dir, "regret.ogg"), dst); err == nil {
+		t.Fatalf("want error of an ogg")
+	}
+}
@@ -0,0 +1,315 @@
+package media
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Store keeps the media files of all the dictionaries in one directory,
+// each file named by the hash of its content, so the same file of two
+// urls is stored once and two files never share a name. The index maps
+// the urls to the names of their files.
+type Store struct {
+	dir string
+	mu  sync.Mutex
+	// index are the names of the files, by url
+	index   map[string]string
+	changed bool
+}
+
+// indexFile is the index of a store, in its directory.
+const indexFile = "index.json"
+
+// OpenStore opens the store of the directory dir, which is created if
+// missing.
+func OpenStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	s := &Store{dir: dir, index: map[string]string{}}
+	buf, err := os.ReadFile(filepath.Join(dir, indexFile))
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buf, &s.index); err != nil {
+		return nil, fmt.Errorf("cannot parse %v: %v", filepath.Join(dir, indexFile), err)
+	}
+	return s, nil
+}
+
+// Dir is the directory of the store.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// File returns the name and path of the file of url, ok is false if the
+// file is not stored.
+func (s *Store) File(url string) (name string, file string, ok bool) {
+	s.mu.Lock()
+	name, ok = s.index[url]
+	s.mu.Unlock()
+	if !ok {
+		return "", "", false
+	}
+	file = s.Path(name)
+	if _, err := os.Stat(file); err != nil {
+		return "", "", false
+	}
+	return name, file, true
+}
+
+// Path returns the path of the file name, in the subdirectory of its
+// kind: audio, pic or other.
+func (s *Store) Path(name string) string {
+	return filepath.Join(s.dir, kindDir(path.Ext(name)), name)
+}
+
+// VariantPath returns the path of a variant of the file name, e.g. its
+// thumbnail, in the subdirectory of the variant.
+func (s *Store) VariantPath(name string, variant string) string {
+	return filepath.Join(s.dir, kindDir(path.Ext(name)), variant, name)
+}
+
+// TempDir is the directory of the files to add, on the file system of
+// the store so they are moved rather than copied.
+func (s *Store) TempDir() string {
+	return filepath.Join(s.dir, "tmp")
+}
+
+// Add adds the file src of url to the store and returns its name. src is
+// moved into the store unless keep, it is removed if the store already
+// has its content.
+func (s *Store) Add(url string, src string, keep bool) (name string, err error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	h.Write(head[:n])
+	_, err = io.Copy(h, f)
+	_ = f.Close()
+	if err != nil {
+		return "", err
+	}
+	name = hex.EncodeToString(h.Sum(nil)[:8]) + extension(url, head[:n])
+
+	file := s.Path(name)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(file); err == nil {
+		// the same content of another url
+		if !keep {
+			_ = os.Remove(src)
+		}
+	} else if keep {
+		err = copyFile(src, file)
+	} else {
+		err = os.Rename(src, file)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.index[url] != name {
+		s.index[url] = name
+		s.changed = true
+	}
+	return name, nil
+}
+
+// Forget removes url from the index, its file is left for the other urls
+// of the same content.
+func (s *Store) Forget(url string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.index[url]; ok {
+		delete(s.index, url)
+		s.changed = true
+	}
+}
+
+// Files returns the names of the files of kind in the store, without
+// their variants.
+func (s *Store) Files(kind Kind) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, string(kind)))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+// Remove removes the file name and its variants from the store, and
+// returns the urls of the file, which are forgotten.
+func (s *Store) Remove(name string) (urls []string, err error) {
+	dir := filepath.Dir(s.Path(name))
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	// the variants may have another extension, e.g. a processed audio
+	base := strings.TrimSuffix(name, path.Ext(name))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		variants, err := filepath.Glob(filepath.Join(dir, entry.Name(), base+".*"))
+		if err != nil {
+			return nil, err
+		}
+		for _, variant := range variants {
+			if err := os.Remove(variant); err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+		}
+	}
+	if err := os.Remove(s.Path(name)); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for u, indexed := range s.index {
+		if indexed == name {
+			urls = append(urls, u)
+			delete(s.index, u)
+			s.changed = true
+		}
+	}
+	sort.Strings(urls)
+	return urls, nil
+}
+
+// Urls returns the indexed urls and the names of their files.
+func (s *Store) Urls() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	urls := make(map[string]string, len(s.index))
+	for u, name := range s.index {
+		urls[u] = name
+	}
+	return urls
+}
+
+// Save writes the index, if it is changed.
+func (s *Store) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.changed {
+		return nil
+	}
+	buf, err := json.MarshalIndent(s.index, "", " ")
+	if err != nil {
+		return err
+	}
+	tmpFile := filepath.Join(s.dir, indexFile+".tmp")
+	if err := os.WriteFile(tmpFile, buf, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpFile, filepath.Join(s.dir, indexFile)); err != nil {
+		return err
+	}
+	s.changed = false
+	return nil
+}
+
+// The extensions of the media files, by content type.
+var extensions = map[string]string{
+	"audio/mpeg":      ".mp3",
+	"application/ogg": ".ogg",
+	"audio/ogg":       ".ogg",
+	"audio/wave":      ".wav",
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+}
+
+// kindDirs are the subdirectories of the files, by extension.
+var kindDirs = map[string]string{
+	".mp3":  "audio",
+	".ogg":  "audio",
+	".wav":  "audio",
+	".jpg":  "pic",
+	".jpeg": "pic",
+	".png":  "pic",
+	".gif":  "pic",
+	".webp": "pic",
+}
+
+func kindDir(ext string) string {
+	if dir, ok := kindDirs[strings.ToLower(ext)]; ok {
+		return dir
+	}
+	return "other"
+}
+
+// extension returns the extension of the file of rawUrl, that of its path
+// if it is a media file, or else the one of the type of its content.
+func extension(rawUrl string, head []byte) string {
+	if u, err := url.Parse(rawUrl); err == nil {
+		ext := strings.ToLower(path.Ext(u.Path))
+		if _, ok := kindDirs[ext]; ok {
+			return ext
+		}
+	}
+	contentType := http.DetectContentType(head)
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+	if ext, ok := extensions[contentType]; ok {
+		return ext
+	}
+	return ".bin"
+}
+
+func copyFile(src string, dst string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmpFile := dst + ".tmp"
+	out, err := os.Create(tmpFile)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmpFile)
+		}
+	}()
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, dst)
+}
@@ -0,0 +1,137 @@
+package media
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, content string) string {
+	file := filepath.Join(t.TempDir(), "download.tmp")
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestStore_Add(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := writeFile(t, "ID3 regret")
+	name, err := s.Add("https://bing.test/us/regret.mp3", src, false)
+	if err != nil {
+		t.Fatalf("cannot add: %v", err)
+	}
+	if !strings.HasSuffix(name, ".mp3") || len(name) != 20 {
+		t.Fatalf("unexpected name: %v", name)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Fatalf("want the file moved, got: %v", err)
+	}
+	if got, file, ok := s.File("https://bing.test/us/regret.mp3"); !ok || got != name || file != filepath.Join(dir, "audio", name) {
+		t.Fatalf("unexpected file: %v, %v, %v", got, file, ok)
+	}
+
+	// the same audio of another dictionary is stored once
+	same, err := s.Add("https://dict.test/regret.mp3", writeFile(t, "ID3 regret"), false)
+	if err != nil || same != name {
+		t.Fatalf("want %v, got: %v, %v", name, same, err)
+	}
+	// the same base name of another audio is not
+	other, err := s.Add("https://webster.test/mp3/regret.mp3", writeFile(t, "ID3 other"), false)
+	if err != nil || other == name {
+		t.Fatalf("want another name than %v, got: %v, %v", name, other, err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, "audio")); len(entries) != 2 {
+		t.Fatalf("want 2 audio files, got: %v", len(entries))
+	}
+
+	// a legacy file is kept
+	legacy := writeFile(t, "ID3 legacy")
+	if _, err := s.Add("https://webster.test/mp3/legacy.mp3", legacy, true); err != nil {
+		t.Fatalf("cannot add: %v", err)
+	}
+	if _, err := os.Stat(legacy); err != nil {
+		t.Fatalf("want the legacy file kept: %v", err)
+	}
+
+	s.Forget("https://dict.test/regret.mp3")
+	if _, _, ok := s.File("https://dict.test/regret.mp3"); ok {
+		t.Fatalf("want the url forgotten")
+	}
+	if _, _, ok := s.File("https://bing.test/us/regret.mp3"); !ok {
+		t.Fatalf("want the file of the other url kept")
+	}
+
+	if err := s.Save(); err != nil {
+		t.Fatalf("cannot save: %v", err)
+	}
+	s, err = OpenStore(dir)
+	if err != nil {
+		t.Fatalf("cannot open: %v", err)
+	}
+	if urls := s.Urls(); len(urls) != 3 || urls["https://webster.test/mp3/regret.mp3"] != other {
+		t.Fatalf("unexpected index: %v", urls)
+	}
+}
+
+func TestExtension(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\n"
+	for _, tt := range []struct {
+		url, head, want string
+	}{
+		{"https://bing.test/pic/regret.JPG", png, ".jpg"},
+		{"https://bing.test/th?id=OIP.regret&w=80", png, ".png"},
+		{"https://bing.test/th?id=OIP.regret", "\xff\xd8\xff\xe0", ".jpg"},
+		{"https://dict.test/audio?word=regret", "ID3\x03", ".mp3"},
+		{"https://dict.test/regret.html", "<html>", ".bin"},
+	} {
+		if got := extension(tt.url, []byte(tt.head)); got != tt.want {
+			t.Errorf("%v: want %v, got: %v", tt.url, tt.want, got)
+		}
+	}
+}
+
+func TestStore_Remove(t *testing.T) {
+	s, err := OpenStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, err := s.Add("https://bing.test/pic/regret.png", writeFile(t, "\x89PNG\r\n\x1a\n"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Add("https://bing.test/th?id=regret", writeFile(t, "\x89PNG\r\n\x1a\n"), false); err != nil {
+		t.Fatal(err)
+	}
+	thumbnail := s.VariantPath(name, "thumb-320")
+	if err := os.MkdirAll(filepath.Dir(thumbnail), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(thumbnail, []byte("thumbnail"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if names, err := s.Files(KindPicture); err != nil || len(names) != 1 || names[0] != name {
+		t.Fatalf("want only %v, got: %v, %v", name, names, err)
+	}
+
+	urls, err := s.Remove(name)
+	if err != nil {
+		t.Fatalf("cannot remove: %v", err)
+	}
+	if len(urls) != 2 || urls[0] != "https://bing.test/pic/regret.png" {
+		t.Fatalf("unexpected urls: %v", urls)
+	}
+	for _, file := range []string{s.Path(name), thumbnail} {
+		if _, err := os.Stat(file); !os.IsNotExist(err) {
+			t.Fatalf("want %v removed, got: %v", file, err)
+		}
+	}
+	if len(s.Urls()) != 0 {
+		t.Fatalf("want an empty index, got: %v", s.Urls())
+	}
+}
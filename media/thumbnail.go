@@ -0,0 +1,134 @@
+// Package media stores and processes the downloaded media files of the
+// words: their audio and pictures.
+package media
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ErrNoThumbnail is returned by Thumbnail for a webp picture, which it
+// cannot decode, the original picture is used instead.
+var ErrNoThumbnail = errors.New("no thumbnail of a webp picture, the original is used")
+
+// Thumbnail writes the picture src scaled down to fit in maxSize x maxSize
+// to dst, in the format of src: jpeg, png or gif. A picture which already
+// fits is copied as it is.
+func Thumbnail(src string, dst string, maxSize int) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	head := make([]byte, 12)
+	n, _ := io.ReadFull(in, head)
+	if isRiff(head[:n], "WEBP") {
+		return ErrNoThumbnail
+	}
+	if _, err := in.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	img, format, err := image.Decode(in)
+	if err != nil {
+		return fmt.Errorf("cannot decode %v: %v", src, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmpFile := dst + ".tmp"
+	out, err := os.Create(tmpFile)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile)
+
+	bounds := img.Bounds()
+	width, height := fit(bounds.Dx(), bounds.Dy(), maxSize)
+	if width == bounds.Dx() && height == bounds.Dy() {
+		if _, err = in.Seek(0, io.SeekStart); err == nil {
+			_, err = io.Copy(out, in)
+		}
+	} else {
+		err = encode(out, Resize(img, width, height), format)
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, dst)
+}
+
+// fit returns the size of a picture of width x height scaled down to fit
+// in maxSize x maxSize, keeping its aspect ratio.
+func fit(width, height, maxSize int) (int, int) {
+	if maxSize <= 0 || width <= maxSize && height <= maxSize {
+		return width, height
+	}
+	if width >= height {
+		return maxSize, max(1, height*maxSize/width)
+	}
+	return max(1, width*maxSize/height), maxSize
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func encode(w io.Writer, img image.Image, format string) error {
+	switch format {
+	case "png":
+		return png.Encode(w, img)
+	case "gif":
+		return gif.Encode(w, img, nil)
+	default:
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: 85})
+	}
+}
+
+// Resize scales img down to width x height, each pixel is the average of
+// the pixels of img it covers.
+func Resize(img image.Image, width, height int) image.Image {
+	bounds := img.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*srcHeight/height
+		y1 := bounds.Min.Y + max((y+1)*srcHeight/height, y*srcHeight/height+1)
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*srcWidth/width
+			x1 := bounds.Min.X + max((x+1)*srcWidth/width, x*srcWidth/width+1)
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					// premultiplied, so the transparent pixels have no color
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					b += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(b / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
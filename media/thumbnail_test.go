@@ -0,0 +1,92 @@
+package media
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestThumbnail(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "pic.png")
+	img := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 400; x++ {
+			// black and white stripes of 2 pixels
+			if x%4 < 2 {
+				img.Set(x, y, color.White)
+			} else {
+				img.Set(x, y, color.Black)
+			}
+		}
+	}
+	f, err := os.Create(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	dst := filepath.Join(dir, "thumb", "pic.png")
+	if err := Thumbnail(src, dst, 100); err != nil {
+		t.Fatalf("cannot make thumbnail: %v", err)
+	}
+	f, err = os.Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	thumb, format, err := image.Decode(f)
+	if err != nil {
+		t.Fatalf("cannot decode thumbnail: %v", err)
+	}
+	if format != "png" || thumb.Bounds().Dx() != 100 || thumb.Bounds().Dy() != 50 {
+		t.Fatalf("want a png of 100x50, got a %v of %v", format, thumb.Bounds())
+	}
+	// the stripes are averaged to gray
+	if r, _, _, _ := thumb.At(10, 10).RGBA(); r>>8 < 120 || r>>8 > 135 {
+		t.Fatalf("want gray, got: %v", thumb.At(10, 10))
+	}
+
+	// a small picture is copied
+	if err := Thumbnail(src, dst, 1000); err != nil {
+		t.Fatal(err)
+	}
+	srcInfo, _ := os.Stat(src)
+	dstInfo, _ := os.Stat(dst)
+	if srcInfo.Size() != dstInfo.Size() {
+		t.Fatalf("want a copy, got %v bytes of %v", dstInfo.Size(), srcInfo.Size())
+	}
+
+	if err := Thumbnail(filepath.Join("thumbnail.go"), dst, 100); err == nil {
+		t.Fatalf("want error of a file which is no picture")
+	}
+
+	webp := filepath.Join(dir, "pic.webp")
+	if err := os.WriteFile(webp, []byte("RIFF\x24\x00\x00\x00WEBPVP8 "), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Thumbnail(webp, filepath.Join(dir, "thumb", "pic.webp"), 100); !errors.Is(err, ErrNoThumbnail) {
+		t.Fatalf("want ErrNoThumbnail of a webp, got: %v", err)
+	}
+}
+
+func TestFit(t *testing.T) {
+	for _, test := range []struct{ w, h, max, wantW, wantH int }{
+		{400, 200, 100, 100, 50},
+		{200, 400, 100, 50, 100},
+		{50, 20, 100, 50, 20},
+		{1000, 1, 100, 100, 1},
+		{400, 200, 0, 400, 200},
+	} {
+		if w, h := fit(test.w, test.h, test.max); w != test.wantW || h != test.wantH {
+			t.Errorf("fit(%v, %v, %v) = %v, %v, want %v, %v", test.w, test.h, test.max, w, h, test.wantW, test.wantH)
+		}
+	}
+}
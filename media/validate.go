@@ -0,0 +1,146 @@
+package media
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"strings"
+)
+
+// Kind is the kind of a media file, also the subdirectory of its files
+// in a store.
+type Kind string
+
+const (
+	KindAudio   Kind = "audio"
+	KindPicture Kind = "pic"
+	KindOther   Kind = "other"
+)
+
+// KindOf returns the kind of the file name, by its extension.
+func KindOf(name string) Kind {
+	return Kind(kindDir(path.Ext(name)))
+}
+
+// MaxSizes are the size limits of the files, by kind. A clip of a word is
+// a few KB, a picture a few hundred.
+var MaxSizes = map[Kind]int64{
+	KindAudio:   4 << 20,
+	KindPicture: 10 << 20,
+}
+
+// HeadSize is the length of the head of a file read by Validate.
+const HeadSize = 512
+
+// Validate returns an error unless head, the first HeadSize bytes of a
+// file of size bytes, is a file of kind: an mp3, ogg or wav audio, or a
+// jpeg, png, gif or webp picture. An html error page is never valid.
+func Validate(kind Kind, head []byte, size int64) error {
+	if size == 0 {
+		return fmt.Errorf("empty file")
+	}
+	if max := MaxSizes[kind]; max > 0 && size > max {
+		return fmt.Errorf("%v bytes, over the limit of %v", size, max)
+	}
+	if isMarkup(head) {
+		return fmt.Errorf("an html or xml page, not %v", kind)
+	}
+	var ok bool
+	switch kind {
+	case KindAudio:
+		ok = isMp3(head) || bytes.HasPrefix(head, []byte("OggS")) || isRiff(head, "WAVE")
+	case KindPicture:
+		ok = bytes.HasPrefix(head, []byte("\xff\xd8\xff")) ||
+			bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")) ||
+			bytes.HasPrefix(head, []byte("GIF87a")) || bytes.HasPrefix(head, []byte("GIF89a")) ||
+			isRiff(head, "WEBP")
+	default:
+		return nil
+	}
+	if !ok {
+		return fmt.Errorf("not %v, starts with %q", kind, head[:min(len(head), 8)])
+	}
+	return nil
+}
+
+// ValidateFile validates the file, of the kind of its name.
+func ValidateFile(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	head := make([]byte, HeadSize)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	return Validate(KindOf(file), head[:n], info.Size())
+}
+
+// ValidateContentType returns an error unless contentType, the header of
+// a response, may be a file of kind. Many servers answer the media files
+// as octet-stream, so it is accepted, as is a missing header.
+func ValidateContentType(kind Kind, contentType string) error {
+	if contentType == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("invalid content type '%v'", contentType)
+	}
+	switch {
+	case mediaType == "application/octet-stream" || mediaType == "binary/octet-stream":
+		return nil
+	case kind == KindAudio && (strings.HasPrefix(mediaType, "audio/") || mediaType == "application/ogg"):
+		return nil
+	case kind == KindPicture && strings.HasPrefix(mediaType, "image/"):
+		return nil
+	case kind != KindAudio && kind != KindPicture:
+		return nil
+	}
+	return fmt.Errorf("content type %v, not %v", mediaType, kind)
+}
+
+// isMp3 returns whether head starts with an id3 tag or an mpeg audio frame.
+func isMp3(head []byte) bool {
+	if bytes.HasPrefix(head, []byte("ID3")) {
+		return true
+	}
+	// the 11 bits of the frame sync, and a layer which is not reserved
+	return len(head) >= 2 && head[0] == 0xff && head[1]&0xe0 == 0xe0 && head[1]&0x06 != 0
+}
+
+func isRiff(head []byte, format string) bool {
+	return len(head) >= 12 && string(head[:4]) == "RIFF" && string(head[8:12]) == format
+}
+
+// isMarkup returns whether head is the start of an html or xml page, e.g.
+// the error page of a server answered with 200.
+func isMarkup(head []byte) bool {
+	text := bytes.TrimLeft(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")), " \t\r\n")
+	if len(text) == 0 || text[0] != '<' {
+		return false
+	}
+	lower := strings.ToLower(string(text[:min(len(text), 16)]))
+	for _, prefix := range []string{"<!doctype", "<html", "<?xml", "<head", "<body", "<!--", "<error"} {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
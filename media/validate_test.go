@@ -0,0 +1,58 @@
+package media
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	for _, tt := range []struct {
+		kind Kind
+		head string
+		size int64
+		err  string
+	}{
+		{KindAudio, "ID3\x03\x00", 5000, ""},
+		{KindAudio, "\xff\xfb\x90\x64", 5000, ""},
+		{KindAudio, "OggS\x00\x02", 5000, ""},
+		{KindAudio, "RIFF\x24\x08\x00\x00WAVEfmt ", 5000, ""},
+		{KindPicture, "\xff\xd8\xff\xe0\x00\x10JFIF", 5000, ""},
+		{KindPicture, "\x89PNG\r\n\x1a\n", 5000, ""},
+		{KindPicture, "GIF89a", 5000, ""},
+		{KindPicture, "RIFF\x24\x08\x00\x00WEBPVP8 ", 5000, ""},
+		{KindAudio, "", 0, "empty file"},
+		{KindAudio, "ID3\x03\x00", 5 << 20, "5242880 bytes, over the limit of 4194304"},
+		{KindAudio, "\xef\xbb\xbf\n <!DOCTYPE html>", 5000, "an html or xml page, not audio"},
+		{KindPicture, "<?xml version=\"1.0\"?><Error>", 5000, "an html or xml page, not pic"},
+		{KindAudio, "\x89PNG\r\n\x1a\n", 5000, `not audio, starts with "\x89PNG\r\n\x1a\n"`},
+		{KindPicture, "ID3", 5000, `not pic, starts with "ID3"`},
+		{KindOther, "<html>", 5000, "an html or xml page, not other"},
+		{KindOther, "%PDF", 5000, ""},
+	} {
+		err := Validate(tt.kind, []byte(tt.head), tt.size)
+		if got := fmt.Sprint(err); tt.err == "" && err != nil || tt.err != "" && got != tt.err {
+			t.Errorf("%v %q: want error %q, got: %v", tt.kind, tt.head, tt.err, err)
+		}
+	}
+}
+
+func TestValidateContentType(t *testing.T) {
+	for _, tt := range []struct {
+		kind        Kind
+		contentType string
+		ok          bool
+	}{
+		{KindAudio, "audio/mpeg", true},
+		{KindAudio, "application/ogg", true},
+		{KindAudio, "application/octet-stream", true},
+		{KindAudio, "", true},
+		{KindAudio, "text/html; charset=utf-8", false},
+		{KindAudio, "image/png", false},
+		{KindPicture, "image/jpeg", true},
+		{KindPicture, "application/json", false},
+	} {
+		if err := ValidateContentType(tt.kind, tt.contentType); (err == nil) != tt.ok {
+			t.Errorf("%v %v: want ok %v, got: %v", tt.kind, tt.contentType, tt.ok, err)
+		}
+	}
+}
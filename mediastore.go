@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"word-downloader/media"
+)
+
+// mediaStore holds the audio and pictures of all the dictionaries, named
+// by their content, nil until opened.
+var mediaStore *media.Store
+
+// mediaDir is the directory of the media store.
+func mediaDir() string {
+	return filepath.Join(*dataDir, "media")
+}
+
+// openMediaStore opens the media store of -data-dir.
+func openMediaStore() error {
+	store, err := media.OpenStore(mediaDir())
+	if err != nil {
+		return err
+	}
+	mediaStore = store
+	return nil
+}
+
+// thumbDir is the variant of the thumbnails of maxSize in the media store,
+// a new size makes new thumbnails.
+func thumbDir(maxSize int) string {
+	return fmt.Sprintf("thumb-%v", maxSize)
+}
+
+// audioProcessing is the processing of the audio of -audio-loudness and
+// -audio-silence.
+func audioProcessing() media.AudioProcess {
+	return media.AudioProcess{Loudness: *audioLoudness, Silence: *audioSilence, Padding: 0.05}
+}
+
+// processAudio writes the processed audio of the file name of the media
+// store, unless it is processed. The original is exported if it cannot be
+// processed, e.g. an ogg.
+func processAudio(name string) {
+	process := audioProcessing()
+	processed := mediaStore.VariantPath(media.ProcessedName(name), process.Variant())
+	if _, err := os.Stat(processed); err == nil {
+		return
+	}
+	if err := process.Process(mediaStore.Path(name), processed); err != nil {
+		log.Printf("error: cannot process audio %v: %v", name, err)
+	}
+}
+
+// mediaFile returns the name of the file of url for the exporters and its
+// path: the processed audio of -audio-process or the thumbnail of
+// -pic-max-size of a picture if it is made. ok is false if the file is not
+// downloaded. The name is stable and unique, the same file of two urls
+// has the same name.
+func mediaFile(url string) (name string, file string, ok bool) {
+	if mediaStore == nil {
+		return "", "", false
+	}
+	name, file, ok = mediaStore.File(url)
+	if ok && *audioProcess && media.KindOf(name) == media.KindAudio {
+		processedName := media.ProcessedName(name)
+		processed := mediaStore.VariantPath(processedName, audioProcessing().Variant())
+		if _, err := os.Stat(processed); err == nil {
+			return processedName, processed, true
+		}
+	}
+	if ok && media.KindOf(name) == media.KindPicture && *picMaxSize > 0 {
+		thumbnail := mediaStore.VariantPath(name, thumbDir(*picMaxSize))
+		if _, err := os.Stat(thumbnail); err == nil {
+			file = thumbnail
+		}
+	}
+	return name, file, ok
+}
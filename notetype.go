@@ -0,0 +1,424 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"regexp"
+	"strings"
+	"word-downloader/anki"
+	"word-downloader/cloze"
+	"word-downloader/dict"
+)
+
+// The kinds of note types, they differ by the templates of their card.
+const (
+	// recognition shows the word and asks for its meaning
+	kindRecognition = "recognition"
+	// recall shows the definition and asks for the word
+	kindRecall = "recall"
+	// cloze asks for the word missing in an example sentence
+	kindCloze = "cloze"
+	// listening plays the audio and asks for the word
+	kindListening = "listening"
+)
+
+// The sources of the fields, see FieldConfig.
+const (
+	sourceWord          = "word"
+	sourcePronunciation = "pronunciation"
+	// sourceDefinition is the html of the dictionaries rendered by the card template
+	sourceDefinition      = "definition"
+	sourceBasicDefinition = "basic-definition"
+	sourceExample         = "example"
+	// sourceCloze is an example with the word and its inflections masked
+	// as a cloze deletion
+	sourceCloze = "cloze"
+	sourceAudio = "audio"
+	// sourcePicture is the thumbnail of the first downloaded picture
+	sourcePicture = "picture"
+)
+
+var sources = map[string]bool{
+	sourceWord:            true,
+	sourcePronunciation:   true,
+	sourceDefinition:      true,
+	sourceBasicDefinition: true,
+	sourceExample:         true,
+	sourceCloze:           true,
+	sourceAudio:           true,
+	sourcePicture:         true,
+}
+
+// kindTemplates are the default card templates of each kind.
+var kindTemplates = map[string]anki.Template{
+	kindRecognition: {
+		Name:  "Recognition",
+		Front: `<div class="this-word">{{Word}}</div>{{Sound}}`,
+		Back:  `{{FrontSide}}<hr id="answer">{{Pronunciation}}{{Picture}}{{Definition}}`,
+	},
+	kindRecall: {
+		Name:  "Recall",
+		Front: `<div class="recall">{{Definition}}</div>`,
+		Back:  `{{FrontSide}}<hr id="answer"><div class="this-word">{{Word}}</div>{{Pronunciation}}{{Sound}}{{Picture}}`,
+	},
+	kindCloze: {
+		Name:  "Cloze",
+		Front: `{{cloze:Text}}`,
+		Back:  `{{cloze:Text}}<hr id="answer"><div class="this-word">{{Word}}</div>{{Pronunciation}}{{Sound}}{{Picture}}{{Definition}}`,
+	},
+	kindListening: {
+		Name:  "Listening",
+		Front: `{{Sound}}`,
+		Back:  `{{FrontSide}}<hr id="answer"><div class="this-word">{{Word}}</div>{{Pronunciation}}{{Picture}}{{Definition}}`,
+	},
+}
+
+// optionalFields are referenced by the templates of the kinds, but may
+// be left out of the configured fields. They are removed from the
+// templates of the note types which do not have them.
+var optionalFields = []string{"Picture"}
+
+// kindFields are the default fields of each kind, those referenced by
+// its templates.
+func kindFields(kind string) []FieldConfig {
+	if kind == kindCloze {
+		return []FieldConfig{
+			{Name: "Word", Source: sourceWord},
+			{Name: "Text", Source: sourceCloze},
+			{Name: "Pronunciation", Source: sourcePronunciation},
+			{Name: "Definition", Source: sourceDefinition},
+			{Name: "Sound", Source: sourceAudio},
+			{Name: "Picture", Source: sourcePicture},
+		}
+	}
+	return []FieldConfig{
+		{Name: "Word", Source: sourceWord},
+		{Name: "Pronunciation", Source: sourcePronunciation},
+		{Name: "Example", Source: sourceExample},
+		{Name: "Definition", Source: sourceDefinition},
+		{Name: "Sound", Source: sourceAudio},
+		{Name: "Picture", Source: sourcePicture},
+	}
+}
+
+// defaultNoteType is exported unless note_types are configured, it is
+// the note type of the earlier versions.
+var defaultNoteType = NoteTypeConfig{Name: "word-downloader", Kind: kindRecognition}
+
+// noteTypes are the note types of the exported notes, the csv file has
+// the notes of the first one.
+var noteTypes = []*noteType{mustNoteType(defaultNoteType)}
+
+// noteType builds the notes of a note type from the looked up words.
+type noteType struct {
+	NoteTypeConfig
+	template anki.Template
+}
+
+// newNoteType checks config and fills in the defaults of its kind.
+func newNoteType(config NoteTypeConfig) (*noteType, error) {
+	if config.Kind == "" {
+		config.Kind = kindRecognition
+	}
+	tmpl, ok := kindTemplates[config.Kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown kind '%v'", config.Kind)
+	}
+	if config.Name == "" {
+		return nil, fmt.Errorf("missing name")
+	}
+	if config.Front != "" {
+		tmpl.Front = config.Front
+	}
+	if config.Back != "" {
+		tmpl.Back = config.Back
+	}
+	if len(config.Fields) == 0 {
+		config.Fields = kindFields(config.Kind)
+	}
+
+	names := map[string]bool{}
+	for i, field := range config.Fields {
+		if field.Name == "" {
+			return nil, fmt.Errorf("fields[%v]: missing name", i)
+		}
+		if names[field.Name] {
+			return nil, fmt.Errorf("fields[%v]: duplicated field '%v'", i, field.Name)
+		}
+		names[field.Name] = true
+		if !sources[field.Source] {
+			return nil, fmt.Errorf("fields[%v] (%v): unknown source '%v'", i, field.Name, field.Source)
+		}
+		if field.Dict != "" {
+			if _, ok := dict.GetProvider(dict.Dictionary(field.Dict)); !ok {
+				return nil, fmt.Errorf("fields[%v] (%v): unsupported dictionary '%v'", i, field.Name, field.Dict)
+			}
+		}
+		if field.Count < 0 {
+			return nil, fmt.Errorf("fields[%v] (%v): count must not be negative", i, field.Name)
+		} else if field.Count > 0 && field.Source != sourceExample && field.Source != sourceCloze && field.Source != sourceAudio {
+			return nil, fmt.Errorf("fields[%v] (%v): count is for example, cloze and audio only", i, field.Name)
+		}
+		switch field.Accent {
+		case "":
+		case dict.AccentUs, dict.AccentUk:
+			if field.Source != sourceAudio {
+				return nil, fmt.Errorf("fields[%v] (%v): accent is for audio only", i, field.Name)
+			}
+		default:
+			return nil, fmt.Errorf("fields[%v] (%v): unknown accent '%v'", i, field.Name, field.Accent)
+		}
+	}
+	for _, name := range optionalFields {
+		if !names[name] {
+			tmpl.Front = strings.ReplaceAll(tmpl.Front, "{{"+name+"}}", "")
+			tmpl.Back = strings.ReplaceAll(tmpl.Back, "{{"+name+"}}", "")
+		}
+	}
+	// the head word identifies the note, see anki.Guid and anki.Client.SyncNote
+	if config.Fields[0].Source != sourceWord {
+		return nil, fmt.Errorf("fields[0]: the first field must be the word")
+	}
+	for _, ref := range append(templateFields(tmpl.Front), templateFields(tmpl.Back)...) {
+		if !names[ref] {
+			return nil, fmt.Errorf("the template references the missing field '%v'", ref)
+		}
+	}
+	if config.Kind == kindCloze && !strings.Contains(tmpl.Front, "{{cloze:") {
+		return nil, fmt.Errorf("the front of a cloze must have a {{cloze:Field}}")
+	}
+	return &noteType{NoteTypeConfig: config, template: tmpl}, nil
+}
+
+func mustNoteType(config NoteTypeConfig) *noteType {
+	t, err := newNoteType(config)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// defaultClozeNoteType is added by -anki-cloze.
+var defaultClozeNoteType = NoteTypeConfig{Name: "word-downloader-cloze", Kind: kindCloze}
+
+// setupNoteTypes replaces noteTypes by the configured ones. withCloze
+// adds the default cloze note type unless a cloze is configured.
+func setupNoteTypes(configs []NoteTypeConfig, withCloze bool) error {
+	if len(configs) == 0 {
+		configs = []NoteTypeConfig{defaultNoteType}
+	}
+	var types []*noteType
+	hasCloze := false
+	for i, config := range configs {
+		t, err := newNoteType(config)
+		if err != nil {
+			return fmt.Errorf("note_types[%v]: %v", i, err)
+		}
+		types = append(types, t)
+		hasCloze = hasCloze || t.Kind == kindCloze
+	}
+	if withCloze && !hasCloze {
+		types = append(types, mustNoteType(defaultClozeNoteType))
+	}
+	noteTypes = types
+	return nil
+}
+
+var templateRef = regexp.MustCompile(`\{\{([^{}]+)\}\}`)
+
+// specialFields are the fields that anki provides to the templates.
+var specialFields = map[string]bool{
+	"FrontSide": true, "Tags": true, "Type": true, "Deck": true,
+	"Subdeck": true, "Card": true, "CardFlag": true,
+}
+
+// templateFields returns the fields referenced by the anki template text.
+func templateFields(text string) []string {
+	var fields []string
+	for _, m := range templateRef.FindAllStringSubmatch(text, -1) {
+		name := strings.TrimLeft(strings.TrimSpace(m[1]), "#^/")
+		// filters, e.g. {{cloze:Text}}
+		if i := strings.LastIndex(name, ":"); i >= 0 {
+			name = name[i+1:]
+		}
+		if name != "" && !specialFields[name] {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// model is the anki note type.
+func (t *noteType) model() anki.Model {
+	var fields []string
+	for _, field := range t.Fields {
+		fields = append(fields, field.Name)
+	}
+	return anki.Model{
+		Name:      t.Name,
+		Fields:    fields,
+		Templates: []anki.Template{t.template},
+		Css:       cardCss,
+		Cloze:     t.Kind == kindCloze,
+	}
+}
+
+// guid is the stable guid of the note of the head word.
+func (t *noteType) guid(headword string) string {
+	return anki.Guid(t.Name, headword)
+}
+
+// ankiNote is a note for an anki collection, with the media it references.
+type ankiNote struct {
+	anki.Note
+	noteType *noteType
+	// media are the files to copy into the collection, by name
+	media map[string]string
+}
+
+// note builds the note of words, ok is false if the note would have no
+// card, e.g. a listening note without audio.
+func (t *noteType) note(words []dict.Word) (note ankiNote, ok bool) {
+	if len(words) == 0 {
+		return note, false
+	}
+	note = ankiNote{noteType: t, media: map[string]string{}}
+	note.Guid = t.guid(words[0].Word())
+	values := map[string]string{}
+	for _, field := range t.Fields {
+		value := fieldValue(field, words, note.media)
+		note.Fields = append(note.Fields, value)
+		values[field.Name] = value
+	}
+	for _, ref := range templateFields(t.template.Front) {
+		if t.Kind == kindCloze && strings.Contains(values[ref], "{{c") {
+			return note, true
+		}
+		if t.Kind != kindCloze && values[ref] != "" {
+			return note, true
+		}
+	}
+	return note, false
+}
+
+// fieldValue returns the value of field from the words of its
+// dictionary, or the first word which has it. The files of the audio
+// are added to media.
+func fieldValue(field FieldConfig, words []dict.Word, media map[string]string) string {
+	headword := words[0].Word()
+	if field.Source == sourceWord {
+		return headword
+	}
+	var candidates []dict.Word
+	for _, word := range words {
+		if field.Dict == "" || word.Type() == dict.Dictionary(field.Dict) {
+			candidates = append(candidates, word)
+		}
+	}
+	switch field.Source {
+	case sourceDefinition:
+		return renderDefinition(headword, candidates)
+	case sourceExample, sourceCloze:
+		return exampleValue(field, headword, candidates)
+	case sourceAudio:
+		return audioValue(field, candidates, media)
+	}
+	for _, word := range candidates {
+		switch field.Source {
+		case sourcePronunciation:
+			if pr := word.Pronunciation(); pr != "" {
+				return cardTemplate.RenderBlock("pronunciation", pr)
+			}
+		case sourceBasicDefinition:
+			if lines := dict.BasicDefinition(word); len(lines) > 0 {
+				for i, line := range lines {
+					lines[i] = html.EscapeString(line)
+				}
+				return strings.Join(lines, "<br>")
+			}
+		case sourcePicture:
+			for _, url := range dict.Pictures(word) {
+				// the picture is dropped if it is not downloaded
+				if name, file, ok := mediaFile(url); ok {
+					media[name] = file
+					return fmt.Sprintf(`<img class="picture" src="%v">`, html.EscapeString(name))
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// audioValue returns the sounds of the count best downloaded audio of the
+// words, see audioPreference. Their files are added to media.
+func audioValue(field FieldConfig, words []dict.Word, media map[string]string) string {
+	count := field.Count
+	if count == 0 {
+		count = *audioClips
+	}
+	var downloaded []dict.Audio
+	names := map[string]bool{}
+	for _, audio := range audioPrefs.rank(words, field.Accent) {
+		// the sound is dropped if its mp3 is not downloaded, or is the
+		// same as another one
+		if name, _, ok := mediaFile(audio.Url); ok && !names[name] {
+			names[name] = true
+			downloaded = append(downloaded, audio)
+		}
+	}
+	var sounds []string
+	for _, audio := range pickAudios(downloaded, count) {
+		name, file, _ := mediaFile(audio.Url)
+		media[name] = file
+		sounds = append(sounds, fmt.Sprintf(`[sound:%v]`, name))
+	}
+	return strings.Join(sounds, "")
+}
+
+// renderDefinition renders the html of the words with the card template.
+func renderDefinition(headword string, words []dict.Word) string {
+	if len(words) == 0 {
+		return ""
+	}
+	view := cardView{Word: headword}
+	for _, word := range words {
+		// the html of the dictionaries is rendered by their templates
+		view.Dicts = append(view.Dicts, cardDictView{Type: word.Type(), Html: template.HTML(word.DefinitionHtml(false))})
+	}
+	return cardTemplate.Render(view)
+}
+
+// exampleValue returns the best example sentences of the words, one
+// per line, see cloze.Select. Each sentence of a cloze is a cloze
+// deletion of its own.
+func exampleValue(field FieldConfig, headword string, words []dict.Word) string {
+	var sentences []string
+	for _, word := range words {
+		sentences = append(sentences, dict.ExampleSentences(word)...)
+	}
+	count := field.Count
+	if count <= 0 {
+		count = 1
+	}
+	var lines []string
+	for i, sentence := range cloze.Select(sentences, headword, count) {
+		if field.Source == sourceCloze {
+			sentence, _ = cloze.Mask(sentence, headword, i+1)
+		}
+		lines = append(lines, html.EscapeString(sentence))
+	}
+	return strings.Join(lines, "<br>")
+}
+
+// buildNotes returns the notes of words of each note type, those without
+// a card are left out.
+func buildNotes(words []dict.Word) []ankiNote {
+	var notes []ankiNote
+	for _, t := range noteTypes {
+		if note, ok := t.note(words); ok {
+			notes = append(notes, note)
+		}
+	}
+	return notes
+}
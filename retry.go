@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"math/rand"
+	"time"
+	"word-downloader/dict"
+)
+
+const maxBackoff = 5 * time.Minute
+
+// retry calls fn until it succeeds, fails with an error which is not
+// retryable, or runs out of retries. It sleeps with a jittered exponential
+// backoff between the attempts.
+func retry(ctx context.Context, what string, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || !dict.IsRetryable(err) || attempt >= *retries || ctx.Err() != nil {
+			return err
+		}
+		delay := backoff(*retryBackoff, attempt)
+		log.Printf(" retry '%v' in %v: %v", what, delay, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// backoff returns a random delay in [d/2, d], where d is base doubled
+// attempt times.
+func backoff(base time.Duration, attempt int) time.Duration {
+	d := base << uint(attempt)
+	if d > maxBackoff || d <= 0 {
+		d = maxBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// failure is a line of audio-error.txt, a lookup or download of Word which
+// failed permanently. Url is empty for lookup failures.
+type failure struct {
+	Word  string
+	Url   string `json:",omitempty"`
+	Error string
+	Time  time.Time
+}
+
+func (d *Downloader) recordFailure(word string, url string, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	buf, _ := json.Marshal(failure{
+		Word:  word,
+		Url:   url,
+		Error: err.Error(),
+		Time:  time.Now(),
+	})
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, err := d.audioErrFile.Write(append(buf, '\n')); err != nil {
+		log.Fatalf("error: cannot write to audio-error.txt: %v", err)
+	}
+}
+
+// takeFailures reads all failures from audio-error.txt and empties it.
+// The failures which are not retried by forgetFailures are written back
+// on close.
+func (d *Downloader) takeFailures() error {
+	if _, err := d.audioErrFile.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(d.audioErrFile)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		var f failure
+		if err := json.Unmarshal(scanner.Bytes(), &f); err != nil {
+			log.Printf("error: skip invalid line of audio-error.txt: %v", scanner.Text())
+			continue
+		}
+		d.failures = append(d.failures, f)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return d.audioErrFile.Truncate(0)
+}
+
+// failedWords returns the words of the taken failures in file order.
+func (d *Downloader) failedWords() []string {
+	var words []string
+	seen := map[string]bool{}
+	for _, f := range d.failures {
+		if !seen[f.Word] {
+			seen[f.Word] = true
+			words = append(words, f.Word)
+		}
+	}
+	return words
+}
+
+// forgetFailures drops the taken failures of word, which is about to be retried.
+func (d *Downloader) forgetFailures(word string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	remain := d.failures[:0]
+	for _, f := range d.failures {
+		if f.Word != word {
+			remain = append(remain, f)
+		}
+	}
+	d.failures = remain
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"word-downloader/dict"
+	"word-downloader/wordlist"
+)
+
+// wordStatuses are the statuses of the words in the personal vocabulary,
+// nil until loaded.
+var wordStatuses *wordlist.Statuses
+
+// statusesPath is the file of the word statuses, next to the caches of
+// the dictionaries.
+func statusesPath() string {
+	return filepath.Join(*dataDir, "word-status.json")
+}
+
+// parseSkipStatus parses the comma separated statuses of -skip-status.
+func parseSkipStatus(s string) (map[wordlist.Status]bool, error) {
+	skip := map[wordlist.Status]bool{}
+	for _, name := range strings.Split(s, ",") {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		status, err := wordlist.ParseStatus(name)
+		if err != nil {
+			return nil, err
+		}
+		skip[status] = true
+	}
+	return skip, nil
+}
+
+// statusFilter returns whether a word is skipped by its status in
+// -skip-status, e.g. a known word.
+func statusFilter() (func(word string) bool, error) {
+	skip, err := parseSkipStatus(*skipStatus)
+	if err != nil {
+		return nil, fmt.Errorf("-skip-status: %v", err)
+	}
+	return func(word string) bool {
+		return wordStatuses != nil && skip[wordStatuses.Get(word)]
+	}, nil
+}
+
+// recordStatus records the keyword of job as a new word once it is found,
+// unless it has a status.
+func recordStatus(job lookupJob, words []dict.Word) {
+	if wordStatuses != nil && len(words) > 0 {
+		wordStatuses.AddNew(job.keyword)
+	}
+}
+
+// statusCommand runs "status <sub>":
+//   - import <status> <file>...: set the status of the words of the files,
+//     e.g. a word list or an anki export, in the format of -word-list-format
+//   - set <status> <word>...: set the status of the words
+//   - list [status]: print the words and their status
+//   - mark: ask the status of each word of -word-list which has none or is new
+func statusCommand(args []string) {
+	statuses, err := wordlist.LoadStatuses(statusesPath())
+	if err != nil {
+		log.Fatalf("error: cannot read word statuses: %v", err)
+	}
+	sub := ""
+	if len(args) > 0 {
+		sub, args = args[0], args[1:]
+	}
+	switch sub {
+	case "import", "set":
+		if len(args) < 2 {
+			_, _ = fmt.Fprintf(os.Stderr, "error: usage: status import <status> <file>..., or status set <status> <word>...\n")
+			os.Exit(1)
+		}
+		status, err := wordlist.ParseStatus(args[0])
+		if err != nil {
+			log.Fatalf("error: %v", err)
+		}
+		words := args[1:]
+		if sub == "import" {
+			words = nil
+			for _, path := range args[1:] {
+				fileWords, err := readWordFile(path)
+				if err != nil {
+					log.Fatalf("error: cannot read %v: %v", path, err)
+				}
+				words = append(words, fileWords...)
+			}
+		}
+		changed := 0
+		for _, word := range words {
+			if statuses.Set(word, status) {
+				changed++
+			}
+		}
+		log.Printf("%v of %v words set to %v", changed, len(words), status)
+	case "list":
+		var status wordlist.Status
+		if len(args) > 0 {
+			if status, err = wordlist.ParseStatus(args[0]); err != nil {
+				log.Fatalf("error: %v", err)
+			}
+		}
+		for _, word := range statuses.Words(status) {
+			fmt.Printf("%v\t%v\n", word, statuses.Get(word))
+		}
+		return
+	case "mark":
+		if *wordList == "" {
+			_, _ = fmt.Fprintf(os.Stderr, "error: status mark reads the answers from stdin, use -word-list\n")
+			os.Exit(1)
+		}
+		words, err := readWordFile(*wordList)
+		if err != nil {
+			log.Fatalf("error: cannot read word list: %v", err)
+		}
+		filter, err := newWordFilter()
+		if err != nil {
+			log.Fatalf("error: %v", err)
+		}
+		filter.Exclude = func(word string) bool {
+			status := statuses.Get(word)
+			return status != "" && status != wordlist.StatusNew
+		}
+		markStatuses(filter.Apply(words), statuses, os.Stdin, os.Stderr)
+	default:
+		_, _ = fmt.Fprintf(os.Stderr, "unknown command: status %v\n", sub)
+		flag.Usage()
+		os.Exit(1)
+	}
+	if err := statuses.Save(statusesPath()); err != nil {
+		log.Fatalf("error: cannot write word statuses: %v", err)
+	}
+}
+
+// readWordFile reads the words of the file path in the format of
+// -word-list-format.
+func readWordFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return wordlist.Read(f, path, *wordListFormat, wordlist.Options{Column: *csvColumn})
+}
+
+// markAnswers are the statuses of the answers of markStatuses.
+var markAnswers = map[string]wordlist.Status{
+	"k": wordlist.StatusKnown,
+	"l": wordlist.StatusLearning,
+	"i": wordlist.StatusIgnored,
+	"n": wordlist.StatusNew,
+}
+
+// markStatuses asks the status of each word on out and reads the answers
+// from in, until all are answered or the answer is q.
+func markStatuses(words []string, statuses *wordlist.Statuses, in io.Reader, out io.Writer) {
+	answers := bufio.NewScanner(in)
+	for i, word := range words {
+		for {
+			_, _ = fmt.Fprintf(out, "[%v/%v] %v: [k]nown, [l]earning, [i]gnored, [n]ew, [q]uit? ", i+1, len(words), word)
+			if !answers.Scan() {
+				_, _ = fmt.Fprintln(out)
+				return
+			}
+			answer := strings.ToLower(strings.TrimSpace(answers.Text()))
+			if answer == "q" {
+				return
+			}
+			if status, ok := markAnswers[answer]; ok {
+				statuses.Set(word, status)
+				break
+			}
+		}
+	}
+}
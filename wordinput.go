@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"word-downloader/wordlist"
+)
+
+// readWords reads the words of the word list r in the format of
+// -word-list-format.
+func readWords(r io.Reader) ([]string, error) {
+	return wordlist.Read(r, *wordList, *wordListFormat, wordlist.Options{Column: *csvColumn})
+}
+
+// newWordFilter returns the preprocessing of the word list given by the
+// flags, with the counts of -frequency-list.
+func newWordFilter() (wordlist.Filter, error) {
+	filter := wordlist.Filter{
+		Dedupe:          *dedupe,
+		Lowercase:       *lowercase,
+		AlphaOnly:       *alphaOnly,
+		SkipProperNouns: *skipProperNouns,
+		MinFrequency:    *minFrequency,
+		Top:             *top,
+	}
+	if *frequencyList == "" {
+		if *minFrequency > 0 {
+			return filter, fmt.Errorf("-min-frequency needs a -frequency-list")
+		}
+		return filter, nil
+	}
+	frequencies, err := wordlist.LoadFrequencies(*frequencyList)
+	if err != nil {
+		return filter, fmt.Errorf("cannot read frequency list: %v", err)
+	}
+	filter.Frequencies = frequencies
+	if *skipProperNouns {
+		if filter.CaseCounts, err = wordlist.LoadCaseCounts(*frequencyList); err != nil {
+			return filter, fmt.Errorf("cannot read frequency list: %v", err)
+		}
+	}
+	return filter, nil
+}
@@ -0,0 +1,174 @@
+package wordlist
+
+import (
+	"archive/zip"
+	"bufio"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ankiSeparators are the separators of the anki text exports, by name.
+var ankiSeparators = map[string]rune{
+	"space":     ' ',
+	"tab":       '\t',
+	"comma":     ',',
+	"semicolon": ';',
+	"pipe":      '|',
+	"colon":     ':',
+}
+
+// soundTag is the audio of an anki field, e.g. [sound:regret.mp3]
+var soundTag = regexp.MustCompile(`\[sound:[^\]]*\]`)
+
+// readAnkiText reads the first field of the notes of an anki text export,
+// "Notes in Plain Text" or the csv of -anki. The file headers tell the
+// separator and the columns of the guid, note type, deck and tags, which
+// are not fields.
+func readAnkiText(r io.Reader, _ string, _ Options) ([]string, error) {
+	buffered := bufio.NewReader(r)
+	comma := '\t'
+	html := false
+	special := map[int]bool{}
+	for {
+		peek, err := buffered.Peek(1)
+		if err != nil || peek[0] != '#' {
+			break
+		}
+		line, err := buffered.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		key, value, _ := cut(strings.TrimSpace(strings.TrimPrefix(line, "#")), ":")
+		switch key = strings.ToLower(key); {
+		case key == "separator":
+			if sep, ok := ankiSeparators[strings.ToLower(value)]; ok {
+				comma = sep
+			} else if runes := []rune(value); len(runes) == 1 {
+				comma = runes[0]
+			} else {
+				return nil, fmt.Errorf("unknown anki separator '%v'", value)
+			}
+		case key == "html":
+			html = value == "true"
+		case strings.HasSuffix(key, " column"):
+			column, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid anki %v '%v'", key, value)
+			}
+			special[column-1] = true
+		}
+	}
+
+	reader := csv.NewReader(buffered)
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	var words []string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		for i, field := range record {
+			if !special[i] {
+				words = append(words, ankiFieldText(field, html))
+				break
+			}
+		}
+	}
+	return words, nil
+}
+
+// ankiFieldText returns the text of an anki field, without its html and
+// audio.
+func ankiFieldText(field string, html bool) string {
+	field = soundTag.ReplaceAllString(field, "")
+	if html {
+		if text, err := htmlText(strings.NewReader(field)); err == nil {
+			field = text
+		}
+	}
+	return strings.Join(strings.Fields(field), " ")
+}
+
+// readApkg reads the first field of the notes of an anki package (.apkg)
+// or collection package (.colpkg). The collections of the newer anki
+// versions compressed with zstd are not supported.
+func readApkg(_ io.Reader, path string, _ Options) ([]string, error) {
+	if path == "" {
+		return nil, fmt.Errorf("the anki package must be a file, not stdin")
+	}
+	pkg, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("not an anki package: %v", err)
+	}
+	defer pkg.Close()
+	files := map[string]*zip.File{}
+	for _, f := range pkg.File {
+		files[f.Name] = f
+	}
+	collection := files["collection.anki21"]
+	if collection == nil {
+		collection = files["collection.anki2"]
+	}
+	if collection == nil {
+		return nil, fmt.Errorf("no collection.anki21 or collection.anki2 in %v, export it with 'support older anki versions'", path)
+	}
+
+	// sqlite needs a file
+	tmp, err := os.CreateTemp("", "collection-*.anki2")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	rc, err := collection.Open()
+	if err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	_, err = io.Copy(tmp, rc)
+	rc.Close()
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite", "file:"+tmp.Name()+"?mode=ro")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	rows, err := db.Query(`SELECT flds FROM notes ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("not an anki collection: %v", err)
+	}
+	defer rows.Close()
+	var words []string
+	for rows.Next() {
+		var fields string
+		if err := rows.Scan(&fields); err != nil {
+			return nil, err
+		}
+		first, _, _ := cut(fields, "\x1f")
+		words = append(words, ankiFieldText(first, true))
+	}
+	return words, rows.Err()
+}
+
+// cut is strings.Cut, which go 1.17 does not have.
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,64 @@
+package wordlist
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// readCsv reads the words of the column opts.Column of the csv r. A
+// column given by name is looked up in the first line, the header.
+func readCsv(r io.Reader, _ string, opts Options) ([]string, error) {
+	reader := csv.NewReader(r)
+	if opts.Comma != 0 {
+		reader.Comma = opts.Comma
+	}
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.Comment = '#'
+
+	column := 0
+	byName := false
+	if opts.Column != "" {
+		index, err := strconv.Atoi(opts.Column)
+		if err != nil {
+			byName = true
+		} else if index < 1 {
+			return nil, fmt.Errorf("csv column %v: the first column is 1", index)
+		} else {
+			column = index - 1
+		}
+	}
+	var words []string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if byName {
+			column = indexOf(record, opts.Column)
+			if column < 0 {
+				return nil, fmt.Errorf("csv column '%v' is not in the header %q", opts.Column, record)
+			}
+			byName = false
+			continue
+		}
+		if column < len(record) {
+			words = append(words, record[column])
+		}
+	}
+	return words, nil
+}
+
+func indexOf(header []string, name string) int {
+	for i, column := range header {
+		if strings.EqualFold(strings.TrimSpace(column), name) {
+			return i
+		}
+	}
+	return -1
+}
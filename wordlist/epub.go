@@ -0,0 +1,161 @@
+package wordlist
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// readEpub extracts the words of the chapters of the epub book r, in the
+// order of its spine.
+func readEpub(r io.Reader, _ string, _ Options) ([]string, error) {
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	book, err := zip.NewReader(bytes.NewReader(buf), int64(len(buf)))
+	if err != nil {
+		return nil, fmt.Errorf("not an epub: %v", err)
+	}
+	files := map[string]*zip.File{}
+	for _, f := range book.File {
+		files[f.Name] = f
+	}
+	chapters, err := epubChapters(files)
+	if err != nil {
+		return nil, err
+	}
+	e := NewExtractor()
+	for _, chapter := range chapters {
+		f, ok := files[chapter]
+		if !ok {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		text, err := htmlText(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", chapter, err)
+		}
+		e.Add(text)
+	}
+	return e.Words(), nil
+}
+
+// epubContainer is META-INF/container.xml, which locates the package
+// document of the book.
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+// epubPackage is the package document (.opf) of the book.
+type epubPackage struct {
+	Items []struct {
+		Id        string `xml:"id,attr"`
+		Href      string `xml:"href,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"manifest>item"`
+	Spine []struct {
+		IdRef string `xml:"idref,attr"`
+	} `xml:"spine>itemref"`
+}
+
+// epubChapters returns the names of the html files of the book in the
+// order of its spine, or in the order of their names without one.
+func epubChapters(files map[string]*zip.File) ([]string, error) {
+	var container epubContainer
+	if err := decodeXml(files["META-INF/container.xml"], &container); err == nil && len(container.Rootfiles) > 0 {
+		opf := container.Rootfiles[0].FullPath
+		var pkg epubPackage
+		if err := decodeXml(files[opf], &pkg); err != nil {
+			return nil, fmt.Errorf("%v: %v", opf, err)
+		}
+		hrefs := map[string]string{}
+		for _, item := range pkg.Items {
+			hrefs[item.Id] = item.Href
+		}
+		var chapters []string
+		for _, ref := range pkg.Spine {
+			if href, ok := hrefs[ref.IdRef]; ok {
+				chapters = append(chapters, path.Join(path.Dir(opf), href))
+			}
+		}
+		if len(chapters) > 0 {
+			return chapters, nil
+		}
+	}
+	var chapters []string
+	for name := range files {
+		switch strings.ToLower(path.Ext(name)) {
+		case ".xhtml", ".html", ".htm":
+			chapters = append(chapters, name)
+		}
+	}
+	sort.Strings(chapters)
+	return chapters, nil
+}
+
+func decodeXml(f *zip.File, v interface{}) error {
+	if f == nil {
+		return fmt.Errorf("missing file")
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return xml.NewDecoder(rc).Decode(v)
+}
+
+// blockElements separate the words of their text from the text around.
+var blockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "td": true, "th": true, "tr": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"title": true, "blockquote": true, "section": true, "dt": true, "dd": true,
+}
+
+// htmlText returns the text of the html r, without its scripts and styles.
+func htmlText(r io.Reader) (string, error) {
+	var text strings.Builder
+	tokenizer := html.NewTokenizer(r)
+	skip := ""
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			if err := tokenizer.Err(); err != io.EOF {
+				return "", err
+			}
+			return text.String(), nil
+		case html.TextToken:
+			if skip == "" {
+				text.Write(tokenizer.Text())
+			}
+		case html.StartTagToken:
+			name, _ := tokenizer.TagName()
+			if tag := string(name); tag == "script" || tag == "style" {
+				skip = tag
+			} else if blockElements[tag] {
+				text.WriteString("\n")
+			}
+		case html.EndTagToken, html.SelfClosingTagToken:
+			name, _ := tokenizer.TagName()
+			if tag := string(name); tag == skip {
+				skip = ""
+			} else if blockElements[tag] {
+				text.WriteString("\n")
+			}
+		}
+	}
+}
@@ -0,0 +1,226 @@
+// Package wordlist reads the word lists and prepares them for lookup.
+package wordlist
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Filter is the preprocessing of a word list, the zero value keeps the
+// words as they are, without the blank ones.
+type Filter struct {
+	// Dedupe drops the words already in the list, ignoring case if Lowercase
+	Dedupe    bool
+	Lowercase bool
+	// AlphaOnly strips the punctuation around the words and drops those
+	// which are not made of letters, e.g. numbers. The words of a phrase
+	// and the hyphenated words are kept.
+	AlphaOnly bool
+	// SkipProperNouns drops the capitalized words, but those the list has
+	// in lower case as well, e.g. at the start of a sentence, and those
+	// CaseCounts has more often in lower case
+	SkipProperNouns bool
+	// Exclude drops the words it reports, e.g. the known words, before
+	// the most frequent ones are kept
+	Exclude func(word string) bool
+	// Frequencies are the counts of the words in lower case, see
+	// LoadFrequencies
+	Frequencies map[string]int
+	// CaseCounts are the counts of the words as written, see
+	// LoadCaseCounts
+	CaseCounts map[string]int
+	// MinFrequency drops the words whose count is less
+	MinFrequency int
+	// Top keeps the Top most frequent words, the most frequent first, 0
+	// keeps all in the order of the list
+	Top int
+}
+
+// Apply returns the words kept by f.
+func (f Filter) Apply(words []string) []string {
+	var out []string
+	seen := map[string]bool{}
+	var lowerCase map[string]bool
+	if f.SkipProperNouns {
+		lowerCase = lowerCaseWords(words)
+	}
+	for _, word := range words {
+		word = strings.Join(strings.Fields(word), " ")
+		if word == "" {
+			continue
+		}
+		if f.AlphaOnly {
+			word = strings.TrimFunc(word, func(r rune) bool {
+				return !unicode.IsLetter(r)
+			})
+			if !isAlpha(word) {
+				continue
+			}
+		}
+		// after the punctuation is stripped, e.g. "Paris,
+		if f.SkipProperNouns && f.isProperNoun(word, lowerCase) {
+			continue
+		}
+		if f.Lowercase {
+			word = strings.ToLower(word)
+		}
+		if f.Exclude != nil && f.Exclude(word) {
+			continue
+		}
+		if f.MinFrequency > 0 && f.frequency(word) < f.MinFrequency {
+			continue
+		}
+		if f.Dedupe {
+			if seen[word] {
+				continue
+			}
+			seen[word] = true
+		}
+		out = append(out, word)
+	}
+	if f.Top > 0 {
+		sort.SliceStable(out, func(i, j int) bool {
+			return f.frequency(out[i]) > f.frequency(out[j])
+		})
+		if len(out) > f.Top {
+			out = out[:f.Top]
+		}
+	}
+	return out
+}
+
+func (f Filter) frequency(word string) int {
+	return f.Frequencies[strings.ToLower(word)]
+}
+
+// isProperNoun tells if word is capitalized, e.g. Alpine, but not an
+// acronym, and is not known in lower case: in lowerCase, the words of the
+// list in lower case, or more often in CaseCounts. A list of lower case
+// words only, e.g. word-list/sorted.txt, knows all words in lower case, and
+// is not used for CaseCounts.
+func (f Filter) isProperNoun(word string, lowerCase map[string]bool) bool {
+	runes := []rune(word)
+	if len(runes) == 0 || !unicode.IsUpper(runes[0]) || len(runes) > 1 && strings.ToUpper(word) == word {
+		return false
+	}
+	lower := strings.ToLower(word)
+	if lowerCase[lower] {
+		return false
+	}
+	return f.CaseCounts[lower] <= f.CaseCounts[word]
+}
+
+// lowerCaseWords returns the words in lower case, without the punctuation
+// around them.
+func lowerCaseWords(words []string) map[string]bool {
+	lowerCase := map[string]bool{}
+	for _, word := range words {
+		word = strings.TrimFunc(strings.Join(strings.Fields(word), " "), func(r rune) bool {
+			return !unicode.IsLetter(r)
+		})
+		if word != "" && strings.ToLower(word) == word {
+			lowerCase[word] = true
+		}
+	}
+	return lowerCase
+}
+
+// isAlpha tells if word is made of letters, with single spaces, hyphens
+// and apostrophes between them.
+func isAlpha(word string) bool {
+	if word == "" {
+		return false
+	}
+	prevLetter := false
+	for _, r := range word {
+		switch {
+		case unicode.IsLetter(r):
+			prevLetter = true
+		case (r == ' ' || r == '-' || r == '\'') && prevLetter:
+			prevLetter = false
+		default:
+			return false
+		}
+	}
+	return prevLetter
+}
+
+// LoadFrequencies reads the counts of a frequency list of "word count"
+// lines, e.g. word-list/sorted.txt. The words are put in lower case.
+func LoadFrequencies(path string) (map[string]int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadFrequencies(f)
+}
+
+// ReadFrequencies reads the counts of the "word count" lines of r, the
+// counts of the same word are added.
+func ReadFrequencies(r io.Reader) (map[string]int, error) {
+	frequencies := map[string]int{}
+	err := readCounts(r, func(word string, count int) {
+		frequencies[strings.ToLower(word)] += count
+	})
+	return frequencies, err
+}
+
+// LoadCaseCounts reads the counts of a frequency list which keeps the case
+// of the words, see ReadCaseCounts.
+func LoadCaseCounts(path string) (map[string]int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadCaseCounts(f)
+}
+
+// ReadCaseCounts reads the counts of the "word count" lines of r by the
+// words as written. It returns nil if no word is capitalized, a list
+// which does not keep the case.
+func ReadCaseCounts(r io.Reader) (map[string]int, error) {
+	counts := map[string]int{}
+	capitalized := false
+	err := readCounts(r, func(word string, count int) {
+		counts[word] += count
+		if strings.ToLower(word) != word {
+			capitalized = true
+		}
+	})
+	if err != nil || !capitalized {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// readCounts calls add with the word and count of each line of r.
+func readCounts(r io.Reader, add func(word string, count int)) error {
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return fmt.Errorf("line %v: want 'word count', got '%v'", line, scanner.Text())
+		}
+		last := len(fields) - 1
+		count, err := strconv.Atoi(fields[last])
+		if err != nil {
+			return fmt.Errorf("line %v: invalid count '%v'", line, fields[last])
+		}
+		// the words of a phrase, e.g. "give up 5"
+		add(strings.Join(fields[:last], " "), count)
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,53 @@
+package wordlist
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFilter_Apply(t *testing.T) {
+	frequencies := map[string]int{"the": 100, "regret": 10, "alpine": 3, "kestrel": 1, "give up": 5}
+	caseCounts := map[string]int{"alpine": 3, "Alpine": 1, "antarctica": 1, "Antarctica": 8, "Paris": 9}
+	words := []string{" regret ", "", "Regret", "the", "Alpine", "Antarctica", "NASA", "kestrel,", "1984", "give  up", "well-known", "\"the\"", "\"Paris,"}
+	for _, test := range []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"none", Filter{}, []string{"regret", "Regret", "the", "Alpine", "Antarctica", "NASA", "kestrel,", "1984", "give up", "well-known", "\"the\"", "\"Paris,"}},
+		{"dedupe", Filter{Dedupe: true, Lowercase: true}, []string{"regret", "the", "alpine", "antarctica", "nasa", "kestrel,", "1984", "give up", "well-known", "\"the\"", "\"paris,"}},
+		{"alpha only", Filter{AlphaOnly: true, Dedupe: true}, []string{"regret", "Regret", "the", "Alpine", "Antarctica", "NASA", "kestrel", "give up", "well-known", "Paris"}},
+		{"proper nouns", Filter{SkipProperNouns: true, AlphaOnly: true}, []string{"regret", "Regret", "the", "NASA", "kestrel", "give up", "well-known", "the"}},
+		{"proper nouns of lower case frequencies", Filter{SkipProperNouns: true, AlphaOnly: true, Frequencies: frequencies}, []string{"regret", "Regret", "the", "NASA", "kestrel", "give up", "well-known", "the"}},
+		{"proper nouns of case counts", Filter{SkipProperNouns: true, AlphaOnly: true, CaseCounts: caseCounts}, []string{"regret", "Regret", "the", "Alpine", "NASA", "kestrel", "give up", "well-known", "the"}},
+		{"min frequency", Filter{AlphaOnly: true, Lowercase: true, Dedupe: true, Frequencies: frequencies, MinFrequency: 3}, []string{"regret", "the", "alpine", "give up"}},
+		{"top", Filter{AlphaOnly: true, Lowercase: true, Dedupe: true, Frequencies: frequencies, Top: 3}, []string{"the", "regret", "give up"}},
+	} {
+		if got := test.filter.Apply(words); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: want %q, got %q", test.name, test.want, got)
+		}
+	}
+}
+
+func TestReadFrequencies(t *testing.T) {
+	got, err := ReadFrequencies(strings.NewReader("the 100\n\nRegret 10\nregret 2\ngive up 5\n"))
+	if err != nil {
+		t.Fatalf("cannot read: %v", err)
+	}
+	if want := map[string]int{"the": 100, "regret": 12, "give up": 5}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+	if _, err := ReadFrequencies(strings.NewReader("the many\n")); err == nil || err.Error() != "line 1: invalid count 'many'" {
+		t.Fatalf("want invalid count, got: %v", err)
+	}
+
+	counts, err := ReadCaseCounts(strings.NewReader("the 100\nRegret 10\nregret 2\nregret 1\n"))
+	if want := map[string]int{"the": 100, "Regret": 10, "regret": 3}; err != nil || !reflect.DeepEqual(counts, want) {
+		t.Fatalf("want %v, got %v, %v", want, counts, err)
+	}
+	// a list in lower case does not keep the case
+	if counts, err := ReadCaseCounts(strings.NewReader("antarctica 10\nalpine 2\n")); err != nil || counts != nil {
+		t.Fatalf("want no counts, got %v, %v", counts, err)
+	}
+}
@@ -0,0 +1,46 @@
+package wordlist
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+
+	_ "modernc.org/sqlite"
+)
+
+// kindleMastered is the category of the words marked as mastered in the
+// vocabulary builder, the others are learning.
+const kindleMastered = 100
+
+// readKindle reads the english words looked up on a kindle from its
+// vocabulary builder database, system/vocabulary/vocab.db, the oldest
+// first. The stem of a word is taken, e.g. regret for regretted, and the
+// mastered words are left out.
+func readKindle(_ io.Reader, path string, _ Options) ([]string, error) {
+	if path == "" {
+		return nil, fmt.Errorf("the kindle vocab.db must be a file, not stdin")
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	rows, err := db.Query(`SELECT word, IFNULL(stem, '') FROM WORDS
+		WHERE lang LIKE 'en%' AND IFNULL(category, 0) != ? ORDER BY timestamp`, kindleMastered)
+	if err != nil {
+		return nil, fmt.Errorf("not a kindle vocab.db: %v", err)
+	}
+	defer rows.Close()
+	var words []string
+	for rows.Next() {
+		var word, stem string
+		if err := rows.Scan(&word, &stem); err != nil {
+			return nil, err
+		}
+		if stem != "" {
+			word = stem
+		}
+		words = append(words, word)
+	}
+	return words, rows.Err()
+}
@@ -0,0 +1,196 @@
+package wordlist
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The formats of the word lists read by the builtin readers.
+const (
+	// FormatAuto detects the format by the extension of the file, a text
+	// file of "word count" lines is a frequency list
+	FormatAuto = "auto"
+	// FormatLines is a word or phrase per line
+	FormatLines = "lines"
+	// FormatFrequency is "word count" lines, e.g. word-list/sorted.txt
+	FormatFrequency = "frequency"
+	// FormatCsv is a column of a csv file, see Options.Column
+	FormatCsv = "csv"
+	// FormatKindle is the vocab.db of the kindle vocabulary builder
+	FormatKindle = "kindle"
+	// FormatText extracts the words of a plain text
+	FormatText = "text"
+	// FormatEpub extracts the words of an epub book
+	FormatEpub = "epub"
+	// FormatSubtitle extracts the words of the srt, vtt or ass subtitles
+	FormatSubtitle = "subtitle"
+	// FormatAnki is the first field of the notes of an anki text export,
+	// or of the csv of -anki
+	FormatAnki = "anki"
+	// FormatApkg is the first field of the notes of an anki package
+	FormatApkg = "apkg"
+)
+
+// Options are the settings of the readers.
+type Options struct {
+	// Column of a csv file, its 1-based index or its name in the header
+	// line, the first column by default
+	Column string
+	// Comma separates the columns of a csv file, ',' by default
+	Comma rune
+}
+
+// ReadFunc reads the words of r, whose file is path, empty for stdin.
+type ReadFunc func(r io.Reader, path string, opts Options) ([]string, error)
+
+var (
+	readersMu sync.RWMutex
+	readers   = map[string]ReadFunc{
+		FormatLines:     readLines,
+		FormatFrequency: readFirstColumn,
+		FormatCsv:       readCsv,
+		FormatKindle:    readKindle,
+		FormatText:      readText,
+		FormatEpub:      readEpub,
+		FormatSubtitle:  readSubtitle,
+		FormatAnki:      readAnkiText,
+		FormatApkg:      readApkg,
+	}
+)
+
+// Register makes a reader available by its format. It panics if the
+// format is registered twice or is auto.
+func Register(format string, read ReadFunc) {
+	readersMu.Lock()
+	defer readersMu.Unlock()
+	if format == FormatAuto {
+		panic("wordlist: Register called for auto")
+	}
+	if _, dup := readers[format]; dup {
+		panic(fmt.Sprintf("wordlist: Register called twice for %v", format))
+	}
+	readers[format] = read
+}
+
+// Formats returns the registered formats, sorted.
+func Formats() []string {
+	readersMu.RLock()
+	defer readersMu.RUnlock()
+	var formats []string
+	for format := range readers {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// extensions are the formats detected by the extension of a file.
+var extensions = map[string]string{
+	".csv":    FormatCsv,
+	".tsv":    FormatCsv,
+	".db":     FormatKindle,
+	".epub":   FormatEpub,
+	".srt":    FormatSubtitle,
+	".vtt":    FormatSubtitle,
+	".ass":    FormatSubtitle,
+	".ssa":    FormatSubtitle,
+	".apkg":   FormatApkg,
+	".colpkg": FormatApkg,
+	".html":   FormatText,
+	".htm":    FormatText,
+}
+
+// Read reads the words of r, whose file is path, empty for stdin, in the
+// given format.
+func Read(r io.Reader, path string, format string, opts Options) ([]string, error) {
+	if format == "" || format == FormatAuto {
+		var err error
+		if format, r, err = detect(r, path); err != nil {
+			return nil, err
+		}
+	}
+	if format == FormatCsv && opts.Comma == 0 && strings.EqualFold(filepath.Ext(path), ".tsv") {
+		opts.Comma = '\t'
+	}
+	readersMu.RLock()
+	read, ok := readers[format]
+	readersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown word list format '%v'", format)
+	}
+	return read(r, path, opts)
+}
+
+// frequencyLine is a line of a frequency list.
+var frequencyLine = regexp.MustCompile(`^\S.*\s\d+\s*$`)
+
+// detect returns the format of the file path by its extension, or by the
+// first line of r, and r to read from the start. A text file is a
+// frequency list if its first line is "word count", or an anki export if
+// it is a header of anki, e.g. #separator:tab.
+func detect(r io.Reader, path string) (string, io.Reader, error) {
+	if format, ok := extensions[strings.ToLower(filepath.Ext(path))]; ok {
+		return format, r, nil
+	}
+	buffered := bufio.NewReader(r)
+	var peeked strings.Builder
+	line := ""
+	for strings.TrimSpace(line) == "" {
+		var err error
+		line, err = buffered.ReadString('\n')
+		peeked.WriteString(line)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return "", nil, err
+		}
+	}
+	format := FormatLines
+	if line = strings.TrimSpace(line); frequencyLine.MatchString(line) {
+		format = FormatFrequency
+	} else if strings.HasPrefix(line, "#separator:") || strings.HasPrefix(line, "#html:") {
+		format = FormatAnki
+	}
+	// read the peeked lines again
+	return format, io.MultiReader(strings.NewReader(peeked.String()), buffered), nil
+}
+
+// readLines reads the lines of r, a word or phrase per line.
+func readLines(r io.Reader, _ string, _ Options) ([]string, error) {
+	var words []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		words = append(words, scanner.Text())
+	}
+	return words, scanner.Err()
+}
+
+// readFirstColumn reads the words of the "word count" lines of r, the
+// count is left out.
+func readFirstColumn(r io.Reader, _ string, _ Options) ([]string, error) {
+	var words []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 1 && isCount(fields[len(fields)-1]) {
+			fields = fields[:len(fields)-1]
+		}
+		words = append(words, strings.Join(fields, " "))
+	}
+	return words, scanner.Err()
+}
+
+func isCount(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
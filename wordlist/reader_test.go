@@ -0,0 +1,134 @@
+package wordlist
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"word-downloader/anki"
+)
+
+func TestRead(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		path    string
+		format  string
+		opts    Options
+		content string
+		want    []string
+	}{
+		{"lines", "", FormatAuto, Options{}, "regret\ngive up\n", []string{"regret", "give up"}},
+		{"detected frequency", "sorted.txt", FormatAuto, Options{}, "\nthat 198501\ngive up 3\nthe\n", []string{"", "that", "give up", "the"}},
+		{"csv by index", "words.csv", FormatAuto, Options{Column: "2"}, "1,regret,遗憾\n2,\"give up\",放弃\n3\n", []string{"regret", "give up"}},
+		{"csv by name", "words.tsv", FormatAuto, Options{Column: "Word"}, "# exported\nid\tword\n1\tregret\n", []string{"regret"}},
+		{"text", "", FormatText, Options{}, "The cat's well-known. the CAT -- don't 42 mp3 a", []string{"the", "cat", "well-known", "don't"}},
+		{"html", "page.html", FormatAuto, Options{}, "<html><head><style>p {color: red}</style></head><p>Regret</p><p>Alpine<b>s</b></p></html>", []string{"Regret", "Alpines"}},
+		{"srt", "movie.srt", FormatAuto, Options{}, "1\n00:00:01,000 --> 00:00:02,000\n<i>Regret</i> nothing\n\n2\n00:00:03,000 --> 00:00:04,000\nRegret it\n", []string{"Regret", "nothing", "it"}},
+		{"vtt", "movie.vtt", FormatAuto, Options{}, "WEBVTT\n\nSTYLE\n::cue { color: yellow }\n\n00:01.000 --> 00:02.000 align:start\nNever regret\n", []string{"Never", "regret"}},
+		{"anki text", "", FormatAuto, Options{}, "#separator:tab\n#html:true\n#guid column:1\n#notetype column:2\nabc\tBasic\t<b>regret</b>[sound:regret.mp3]\t遗憾\ndef\tBasic\tgive&nbsp;up\t放弃\n", []string{"regret", "give up"}},
+		{"anki csv", "anki-flashcard.csv", FormatAnki, Options{}, "#separator:Pipe\n#html:true\n#notetype:word-downloader\n#guid column:6\nregret|/rɪˈɡret/|||[sound:regret.mp3]|abc\n", []string{"regret"}},
+		{"ass", "movie.ass", FormatAuto, Options{}, "[Script Info]\nTitle: Movie\n\n[Events]\nFormat: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\nDialogue: 0,0:00:01.00,0:00:02.00,Default,,0,0,0,,{\\an8}Regret, I said\\Nnothing\n", []string{"Regret", "said", "nothing"}},
+	} {
+		got, err := Read(strings.NewReader(test.content), test.path, test.format, test.opts)
+		if err != nil {
+			t.Errorf("%v: cannot read: %v", test.name, err)
+		} else if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: want %q, got %q", test.name, test.want, got)
+		}
+	}
+
+	if _, err := Read(strings.NewReader("id,word\n"), "words.csv", FormatCsv, Options{Column: "lemma"}); err == nil {
+		t.Errorf("want error of a missing csv column")
+	}
+	if _, err := Read(strings.NewReader("regret"), "", "pdf", Options{}); err == nil || err.Error() != "unknown word list format 'pdf'" {
+		t.Errorf("want unknown format, got: %v", err)
+	}
+}
+
+func TestRead_Kindle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vocab.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, stmt := range []string{
+		`CREATE TABLE WORDS (id TEXT PRIMARY KEY NOT NULL, word TEXT, stem TEXT, lang TEXT, category INTEGER DEFAULT 0, timestamp INTEGER DEFAULT 0, profileid TEXT)`,
+		`INSERT INTO WORDS VALUES ('en:regretted', 'regretted', 'regret', 'en', 0, 2, '')`,
+		`INSERT INTO WORDS VALUES ('en:kestrel', 'kestrel', NULL, 'en-GB', 0, 1, '')`,
+		`INSERT INTO WORDS VALUES ('en:the', 'the', 'the', 'en', 100, 3, '')`,
+		`INSERT INTO WORDS VALUES ('fr:regret', 'regret', 'regret', 'fr', 0, 4, '')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := Read(nil, path, FormatAuto, Options{})
+	if err != nil {
+		t.Fatalf("cannot read: %v", err)
+	}
+	if want := []string{"kestrel", "regret"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+	if _, err := Read(strings.NewReader(""), "", FormatKindle, Options{}); err == nil {
+		t.Fatalf("want error of stdin")
+	}
+}
+
+func TestRead_Apkg(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deck.apkg")
+	deck := anki.Deck{
+		Name:   "english",
+		Models: []anki.Model{{Name: "basic", Fields: []string{"Front", "Back"}, Templates: []anki.Template{{Name: "card", Front: "{{Front}}", Back: "{{Back}}"}}}},
+		Notes: []anki.Note{
+			{Fields: []string{"<div>regret</div>", "遗憾"}},
+			{Fields: []string{"kestrel [sound:kestrel.mp3]", "红隼"}},
+		},
+	}
+	if err := anki.WriteApkg(path, deck); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Read(nil, path, FormatAuto, Options{})
+	if err != nil {
+		t.Fatalf("cannot read: %v", err)
+	}
+	if want := []string{"regret", "kestrel"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
+
+func TestRead_Epub(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"mimetype":               "application/epub+zip",
+		"META-INF/container.xml": `<container><rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles></container>`,
+		"OEBPS/content.opf": `<package><manifest>
+			<item id="c2" href="text/two.xhtml" media-type="application/xhtml+xml"/>
+			<item id="c1" href="text/one.xhtml" media-type="application/xhtml+xml"/>
+			</manifest><spine><itemref idref="c1"/><itemref idref="c2"/></spine></package>`,
+		"OEBPS/text/one.xhtml": `<html><body><h1>Chapter One</h1><p>He regretted it.</p><script>var x;</script></body></html>`,
+		"OEBPS/text/two.xhtml": `<html><body><p>Chapter two, no regret.</p></body></html>`,
+	} {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Read(&buf, "book.epub", FormatAuto, Options{})
+	if err != nil {
+		t.Fatalf("cannot read: %v", err)
+	}
+	if want := []string{"Chapter", "One", "He", "regretted", "it", "two", "no", "regret"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
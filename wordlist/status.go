@@ -0,0 +1,135 @@
+package wordlist
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Status is the state of a word in the personal vocabulary.
+type Status string
+
+const (
+	// StatusNew is a word looked up but not studied yet
+	StatusNew      Status = "new"
+	StatusLearning Status = "learning"
+	StatusKnown    Status = "known"
+	// StatusIgnored is a word not worth studying, e.g. a typo or a name
+	StatusIgnored Status = "ignored"
+)
+
+// ParseStatus returns the status named s.
+func ParseStatus(s string) (Status, error) {
+	switch status := Status(strings.ToLower(strings.TrimSpace(s))); status {
+	case StatusNew, StatusLearning, StatusKnown, StatusIgnored:
+		return status, nil
+	}
+	return "", fmt.Errorf("unknown status '%v', want new, learning, known or ignored", s)
+}
+
+// Statuses are the statuses of the words, by the words in lower case.
+// The zero value is not usable, see LoadStatuses.
+type Statuses struct {
+	mu       sync.Mutex
+	statuses map[string]Status
+}
+
+// LoadStatuses reads the statuses of the json file path, a missing file
+// has none.
+func LoadStatuses(path string) (*Statuses, error) {
+	s := &Statuses{statuses: map[string]Status{}}
+	buf, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buf, &s.statuses); err != nil {
+		return nil, fmt.Errorf("cannot parse %v: %v", path, err)
+	}
+	for word, status := range s.statuses {
+		if _, err := ParseStatus(string(status)); err != nil {
+			return nil, fmt.Errorf("%v: %v: %v", path, word, err)
+		}
+	}
+	return s, nil
+}
+
+func statusKey(word string) string {
+	return strings.ToLower(strings.Join(strings.Fields(word), " "))
+}
+
+// Get returns the status of word, empty if it has none.
+func (s *Statuses) Get(word string) Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.statuses[statusKey(word)]
+}
+
+// Set sets the status of word. It tells if the status is changed.
+func (s *Statuses) Set(word string, status Status) bool {
+	key := statusKey(word)
+	if key == "" {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.statuses[key] == status {
+		return false
+	}
+	s.statuses[key] = status
+	return true
+}
+
+// AddNew sets the status of word to new, unless it has one. It tells if
+// the status is set.
+func (s *Statuses) AddNew(word string) bool {
+	key := statusKey(word)
+	if key == "" {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.statuses[key]; ok {
+		return false
+	}
+	s.statuses[key] = StatusNew
+	return true
+}
+
+// Words returns the words of the status, sorted, or all of them if status
+// is empty.
+func (s *Statuses) Words(status Status) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var words []string
+	for word, st := range s.statuses {
+		if status == "" || st == status {
+			words = append(words, word)
+		}
+	}
+	sort.Strings(words)
+	return words
+}
+
+// Save writes the statuses to the json file path.
+func (s *Statuses) Save(path string) error {
+	s.mu.Lock()
+	buf, err := json.MarshalIndent(s.statuses, "", " ")
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmpFile := path + ".tmp"
+	if err := os.WriteFile(tmpFile, buf, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, path)
+}
@@ -0,0 +1,45 @@
+package wordlist
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStatuses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "word-status.json")
+	s, err := LoadStatuses(path)
+	if err != nil {
+		t.Fatalf("want no statuses of a missing file, got: %v", err)
+	}
+	if !s.Set("Regret ", StatusKnown) || s.Set("regret", StatusKnown) {
+		t.Fatalf("want the status set once")
+	}
+	if !s.AddNew("kestrel") || s.AddNew("regret") {
+		t.Fatalf("want only the words without a status new")
+	}
+	s.Set("give  up", StatusIgnored)
+	if err := s.Save(path); err != nil {
+		t.Fatalf("cannot save: %v", err)
+	}
+
+	s, err = LoadStatuses(path)
+	if err != nil {
+		t.Fatalf("cannot load: %v", err)
+	}
+	if got := s.Get("REGRET"); got != StatusKnown {
+		t.Fatalf("want regret known, got: %v", got)
+	}
+	if got := s.Get("give up"); got != StatusIgnored {
+		t.Fatalf("want give up ignored, got: %v", got)
+	}
+	if got, want := s.Words(""), []string{"give up", "kestrel", "regret"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+	if got, want := s.Words(StatusNew), []string{"kestrel"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+	if _, err := ParseStatus("mastered"); err == nil {
+		t.Fatalf("want error of an unknown status")
+	}
+}
@@ -0,0 +1,124 @@
+package wordlist
+
+import (
+	"bufio"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Extractor collects the unique words of texts, in the order they are
+// first seen. A word is in lower case unless it is always capitalized,
+// e.g. a name, so the capital of the first word of a sentence is lost.
+type Extractor struct {
+	words []string
+	// index of the words, by lower case
+	index map[string]int
+}
+
+func NewExtractor() *Extractor {
+	return &Extractor{index: map[string]int{}}
+}
+
+// Add adds the words of text. The tokens of a single letter or with
+// digits are left out, as is the possessive 's.
+func (e *Extractor) Add(text string) {
+	tokens := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\'' && r != '’' && r != '-'
+	})
+	for _, token := range tokens {
+		token = strings.ReplaceAll(token, "’", "'")
+		for _, word := range strings.Split(token, "--") {
+			e.add(word)
+		}
+	}
+}
+
+func (e *Extractor) add(word string) {
+	word = strings.Trim(word, "'-")
+	word = strings.TrimSuffix(word, "'s")
+	if len([]rune(word)) < 2 || strings.IndexFunc(word, unicode.IsDigit) >= 0 {
+		return
+	}
+	lower := strings.ToLower(word)
+	if i, ok := e.index[lower]; ok {
+		if word == lower {
+			e.words[i] = lower
+		}
+		return
+	}
+	e.index[lower] = len(e.words)
+	e.words = append(e.words, word)
+}
+
+// Words returns the words added so far.
+func (e *Extractor) Words() []string {
+	return append([]string(nil), e.words...)
+}
+
+// readText extracts the words of the plain text or html r.
+func readText(r io.Reader, path string, _ Options) ([]string, error) {
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".html" || ext == ".htm" {
+		text, err := htmlText(r)
+		if err != nil {
+			return nil, err
+		}
+		r = strings.NewReader(text)
+	}
+	e := NewExtractor()
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		e.Add(scanner.Text())
+	}
+	return e.Words(), scanner.Err()
+}
+
+var (
+	// subtitleTags are the html tags of srt and vtt, and the override
+	// blocks of ass, e.g. <i> or {\an8}
+	subtitleTags = regexp.MustCompile(`<[^>]*>|\{[^}]*\}`)
+	// assBreaks are the line breaks and hard spaces of ass
+	assBreaks = strings.NewReplacer(`\N`, " ", `\n`, " ", `\h`, " ")
+)
+
+// readSubtitle extracts the words of the srt, vtt or ass subtitles r,
+// without the timings, cue settings and styles.
+func readSubtitle(r io.Reader, path string, _ Options) ([]string, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	ass := ext == ".ass" || ext == ".ssa"
+	e := NewExtractor()
+	// in a NOTE, STYLE or REGION block of vtt, up to a blank line
+	inBlock := false
+	scanner := bufio.NewScanner(r)
+	for first := true; scanner.Scan(); first = false {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\uFEFF"))
+		if first && line == "[Script Info]" {
+			ass = true
+		}
+		if ass {
+			// Dialogue: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+			if !strings.HasPrefix(line, "Dialogue:") {
+				continue
+			}
+			fields := strings.SplitN(line, ",", 10)
+			if len(fields) < 10 {
+				continue
+			}
+			line = assBreaks.Replace(fields[9])
+		} else {
+			if line == "" {
+				inBlock = false
+			} else if strings.HasPrefix(line, "NOTE") || strings.HasPrefix(line, "STYLE") || strings.HasPrefix(line, "REGION") {
+				inBlock = true
+			}
+			if inBlock || strings.Contains(line, "-->") || strings.HasPrefix(line, "WEBVTT") {
+				continue
+			}
+		}
+		e.Add(subtitleTags.ReplaceAllString(line, " "))
+	}
+	return e.Words(), scanner.Err()
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"word-downloader/dict"
+
+	"golang.org/x/time/rate"
+)
+
+// parseRates parses a rate spec like "webster=2/s,dictcn=30/m".
+func parseRates(spec string) (map[dict.Dictionary]rate.Limit, error) {
+	rates := map[dict.Dictionary]rate.Limit{}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid rate '%v', want <dict>=<n>/<s|m|h>", item)
+		}
+		limit, err := parseRate(kv[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate '%v': %v", item, err)
+		}
+		rates[dict.Dictionary(strings.TrimSpace(kv[0]))] = limit
+	}
+	return rates, nil
+}
+
+// parseRate parses "<n>/<unit>", unit is one of s, m and h.
+func parseRate(s string) (rate.Limit, error) {
+	parts := strings.SplitN(strings.TrimSpace(s), "/", 2)
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("missing unit")
+	}
+	n, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid number '%v'", parts[0])
+	}
+	switch parts[1] {
+	case "s":
+		return rate.Limit(n), nil
+	case "m":
+		return rate.Limit(n / 60), nil
+	case "h":
+		return rate.Limit(n / 3600), nil
+	default:
+		return 0, fmt.Errorf("invalid unit '%v'", parts[1])
+	}
+}
+
+type lookupJob struct {
+	index   int
+	keyword string
+	// original is the keyword of the word list, before it is lemmatized
+	original string
+}
+
+type lookupResult struct {
+	index int
+	words []dict.Word
+}
+
+// runWorkers looks up each job with all downloaders using n workers.
+// Results of interrupted jobs are dropped.
+func runWorkers(ctx context.Context, n int, downloaders []*Downloader, jobs <-chan lookupJob) <-chan lookupResult {
+	results := make(chan lookupResult)
+	wg := sync.WaitGroup{}
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				var words []dict.Word
+				for _, downloader := range downloaders {
+					word, _, err := downloader.download(ctx, job.keyword)
+					if err == nil {
+						words = append(words, word)
+					}
+				}
+				if ctx.Err() != nil {
+					continue
+				}
+				recordLemma(job, words)
+				recordStatus(job, words)
+				results <- lookupResult{index: job.index, words: words}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+// writeResults writes results to the anki file in input order. It returns
+// the number of words written, which stops at the first dropped job or
+// once an export is interrupted by ctx.
+func writeResults(ctx context.Context, results <-chan lookupResult, exporters []exporter) int {
+	pending := map[int][]dict.Word{}
+	next := 0
+	interrupted := false
+	for result := range results {
+		if interrupted {
+			// the workers stop once ctx is done
+			continue
+		}
+		pending[result.index] = result.words
+		for !interrupted {
+			words, ok := pending[next]
+			if !ok {
+				break
+			}
+			for _, e := range exporters {
+				if len(words) == 0 {
+					continue
+				}
+				if err := e.add(ctx, words); err != nil && ctx.Err() != nil {
+					log.Printf("interrupted, cannot export: %v", err)
+					interrupted = true
+					break
+				} else if err != nil {
+					log.Fatalf("error: cannot write to anki file: %v", err)
+				}
+			}
+			if interrupted {
+				break
+			}
+			delete(pending, next)
+			log.Printf("finish: %v", next)
+			next++
+		}
+	}
+	return next
+}